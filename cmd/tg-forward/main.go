@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -11,28 +14,47 @@ import (
 	"time"
 
 	"github.com/gabrielmelo/tg-forward/internal/api"
+	"github.com/gabrielmelo/tg-forward/internal/commands"
 	"github.com/gabrielmelo/tg-forward/internal/config"
+	"github.com/gabrielmelo/tg-forward/internal/delivery"
+	"github.com/gabrielmelo/tg-forward/internal/health"
+	"github.com/gabrielmelo/tg-forward/internal/leader"
+	"github.com/gabrielmelo/tg-forward/internal/links"
+	"github.com/gabrielmelo/tg-forward/internal/logging"
 	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/matches"
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
 	"github.com/gabrielmelo/tg-forward/internal/rules"
 	"github.com/gabrielmelo/tg-forward/internal/telegram"
+	"github.com/gabrielmelo/tg-forward/internal/tracing"
 	"github.com/gotd/td/tg"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/gabrielmelo/tg-forward/cmd/tg-forward")
+
 func main() {
 	flag.Parse()
 
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+		slog.Info("No .env file found, using environment variables")
 	}
 
-	log.Println("Starting Telegram Message Forwarder...")
+	slog.Info("Starting Telegram Message Forwarder")
 
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
+	}
+
+	if err := logging.Setup(cfg.Logging.Format, cfg.Logging.Level); err != nil {
+		fatal("Failed to configure logging", err)
 	}
 
 	apiPort := cfg.API.Port
@@ -42,20 +64,32 @@ func main() {
 
 	ctx := context.Background()
 
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Enabled, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
+
 	db, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoDB.URI))
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		fatal("Failed to connect to MongoDB", err)
 	}
 	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		if err := db.Disconnect(ctx); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
+			slog.Error("Error disconnecting from MongoDB", "error", err)
 		}
 	}()
 
 	if err := db.Ping(ctx, nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+		fatal("Failed to ping MongoDB", err)
 	}
 
 	rulesRepo, err := rules.NewRepository(
@@ -64,48 +98,170 @@ func main() {
 		"rules",
 	)
 	if err != nil {
-		log.Fatalf("Failed to initialize rules repository: %v", err)
+		fatal("Failed to initialize rules repository", err)
 	}
 	defer rulesRepo.Close()
 
 	patterns, err := rulesRepo.GetPatterns()
 	if err != nil {
-		log.Fatalf("Failed to get patterns: %v", err)
+		fatal("Failed to get patterns", err)
 	}
 
 	m, err := matcher.New(patterns)
 	if err != nil {
-		log.Fatalf("Failed to initialize matcher: %v", err)
+		fatal("Failed to initialize matcher", err)
 	}
 
 	rulesService := rules.NewService(rulesRepo, m)
 
-	bot, err := telegram.NewBot(
-		cfg.Telegram.Bot.Token,
-		cfg.Telegram.Bot.TargetChatID,
-		cfg.Telegram.Bot.TargetUsername,
+	bots := telegram.NewBots()
+	for _, botCfg := range cfg.Telegram.Bots {
+		bot, err := telegram.NewBot(botCfg.Token)
+		if err != nil {
+			fatal("Failed to initialize bot", err, "bot", botCfg.Name)
+		}
+		bots.Add(botCfg.Name, bot)
+	}
+
+	var targets []string
+	for _, target := range cfg.Telegram.Targets {
+		if err := bots.AddTarget(target.Name, target.Bot, telegram.Target{
+			ChatID:   target.ChatID,
+			Username: target.Username,
+		}); err != nil {
+			fatal("Failed to configure delivery target", err)
+		}
+		targets = append(targets, target.Name)
+	}
+	rulesService.SetTargets(targets)
+
+	deliveryRepo, err := delivery.NewRepository(db, cfg.MongoDB.Database)
+	if err != nil {
+		fatal("Failed to initialize delivery repository", err)
+	}
+
+	dispatcher := delivery.NewDispatcher(
+		bots,
+		rulesService,
+		deliveryRepo,
+		delivery.Limit{
+			PerMinute: cfg.Delivery.TargetPerMinute,
+			Burst:     cfg.Delivery.TargetBurst,
+		},
+		cfg.Delivery.MaxQueue,
+	)
+	if len(cfg.Telegram.Commands.AdminIDs) > 0 {
+		dispatcher.EnableFeedback()
+	}
+
+	matchStore, err := matches.NewRepository(
+		db,
+		cfg.MongoDB.Database,
+		time.Duration(cfg.Matches.RetentionDays)*24*time.Hour,
 	)
 	if err != nil {
-		log.Fatalf("Failed to initialize bot: %v", err)
+		fatal("Failed to initialize match history", err)
 	}
 
-	apiServer := api.NewServer(rulesService, apiPort, cfg.API.Token)
+	rulesService.SetStatsSource(matchStore)
+	dispatcher.OnStatus(func(eventID string, status delivery.Status, err error) {
+		if updateErr := matchStore.UpdateStatus(eventID, status, err); updateErr != nil {
+			slog.Error("Failed to update match status", "event_id", eventID, "error", updateErr)
+		}
+	})
+
+	elector := leader.NewElector(
+		db,
+		cfg.MongoDB.Database,
+		"forwarder",
+		cfg.Leader.ID,
+		time.Duration(cfg.Leader.LeaseSeconds)*time.Second,
+	)
+
+	checker := health.NewChecker(5 * time.Second)
+	checker.AddInfo("instance", elector.ID)
+	checker.AddInfo("role", func() string {
+		if elector.IsLeader() {
+			return "leader"
+		}
+		return "follower"
+	})
+	checker.Add("mongo", func(ctx context.Context) error {
+		return db.Ping(ctx, nil)
+	})
+	for _, botCfg := range cfg.Telegram.Bots {
+		bot, _ := bots.Get(botCfg.Name)
+		checker.Add("bot:"+botCfg.Name, bot.Ping)
+	}
+	checker.Add("outbox", func(ctx context.Context) error {
+		if backlog := dispatcher.Backlog(); backlog > cfg.Health.MaxOutbox {
+			return fmt.Errorf("%d messages waiting, limit is %d", backlog, cfg.Health.MaxOutbox)
+		}
+		return nil
+	})
+
+	apiServer := api.NewServer(rulesService, matchStore, checker, apiPort, cfg.API.Token)
+
+	var resolver *links.Resolver
+	if cfg.Links.Resolve {
+		shorteners := cfg.Links.Shorteners
+		if len(shorteners) == 0 {
+			shorteners = links.DefaultShorteners
+		}
+		resolver = links.NewResolver(
+			&http.Client{Timeout: time.Duration(cfg.Links.TimeoutSeconds) * time.Second},
+			shorteners,
+		)
+		resolver.SetBudget(time.Duration(cfg.Links.BudgetSeconds) * time.Second)
+	}
 
-	var mu sync.RWMutex
-	messageHandler := func(ctx context.Context, msg *tg.Message) error {
+	messageHandler := func(ctx context.Context, msg *tg.Message, match *matcher.Message) error {
 		text := extractMessageText(msg)
-		if text == "" {
+		if text == "" && match.Media == "" {
 			return nil
 		}
 
-		mu.RLock()
-		currentMatcher := apiServer.GetMatcher()
-		mu.RUnlock()
+		ctx, span := tracer.Start(ctx, "pipeline.process")
+		defer span.End()
 
-		if currentMatcher.Match(text) {
-			log.Printf("Message matched pattern, forwarding")
-			if err := bot.ForwardMessage(text); err != nil {
-				log.Printf("Failed to forward message: %v", err)
+		resolveCtx, resolveSpan := tracer.Start(ctx, "links.resolve", trace.WithAttributes(
+			attribute.Int("links.count", len(match.URLs)),
+		))
+		match.URLs = resolver.ResolveAll(resolveCtx, match.URLs)
+		resolveSpan.End()
+
+		if ruleIDs := rulesService.GetMatcher().MatchMessageContext(ctx, match); len(ruleIDs) > 0 {
+			ctx = logging.With(ctx, slog.Any("rule_ids", ruleIDs))
+			slog.InfoContext(ctx, "Message matched, forwarding")
+			for _, id := range ruleIDs {
+				metrics.RuleMatches.WithLabelValues(id).Inc()
+			}
+			eventID, recordErr := matchStore.Record(matches.Event{
+				Account:   match.Account,
+				Chat:      match.Chat,
+				MessageID: msg.ID,
+				Sender:    matches.Sender{ID: match.Sender.ID, Username: match.Sender.Username},
+				Text:      text,
+				RuleIDs:   ruleIDs,
+				RuleID:    ruleIDs[0],
+				Status:    matches.StatusPending,
+				SentAt:    time.Unix(int64(msg.Date), 0),
+			})
+			if recordErr != nil {
+				slog.ErrorContext(ctx, "Failed to record match", "error", recordErr)
+			}
+
+			status, err := dispatcher.DeliverEvent(ctx, eventID, ruleIDs, match)
+			if eventID != "" {
+				if updateErr := matchStore.UpdateStatus(eventID, status, err); updateErr != nil {
+					slog.ErrorContext(ctx, "Failed to update match status", "error", updateErr)
+				}
+			}
+
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to forward message", "status", status, "error", err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
 				return err
 			}
 		}
@@ -113,14 +269,32 @@ func main() {
 		return nil
 	}
 
-	client := telegram.NewClient(
-		cfg.Telegram.User.AppID,
-		cfg.Telegram.User.AppHash,
-		cfg.Telegram.User.Phone,
-		messageHandler,
-		bot.GetBotID(),
-		cfg.Telegram.User.Session,
-	)
+	stateRepo := telegram.NewStateRepository(db, cfg.MongoDB.Database)
+	supervisor := telegram.NewSupervisor()
+	for _, user := range cfg.Telegram.Users {
+		client := telegram.NewClient(
+			user.Name,
+			user.AppID,
+			user.AppHash,
+			user.Phone,
+			messageHandler,
+			bots.IDs(),
+			user.Session,
+		)
+		client.EnableCatchUp(stateRepo, time.Duration(cfg.Telegram.CatchUpMaxMinutes)*time.Minute)
+		supervisor.Add(client, user.AppID, user.AppHash)
+		checker.Add("user:"+user.Name, func(ctx context.Context) error {
+			if !elector.IsLeader() {
+				return nil
+			}
+			return client.Ready(ctx)
+		})
+	}
+
+	rulesService.SetHistorySource(backtestHistory{supervisor})
+	if resolver != nil {
+		rulesService.SetLinkResolver(resolver)
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
@@ -130,33 +304,86 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		log.Println("Starting API server...")
+		slog.Info("Starting API server")
 		if err := apiServer.Start(); err != nil {
-			log.Printf("API server error: %v", err)
+			slog.Error("API server error", "error", err)
 		}
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		log.Println("Starting user client...")
-		if err := client.Run(ctx, cfg.Telegram.User.AppID, cfg.Telegram.User.AppHash); err != nil {
-			log.Printf("Client error: %v", err)
+		rulesService.Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rulesService.Watch(ctx)
+	}()
+
+	lead := func(ctx context.Context) {
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dispatcher.Run(ctx)
+		}()
+
+		if len(cfg.Telegram.Commands.AdminIDs) > 0 {
+			commandHandler := commands.NewHandler(rulesService, cfg.Telegram.Commands.AdminIDs)
+
+			for _, botCfg := range cfg.Telegram.Bots {
+				bot, _ := bots.Get(botCfg.Name)
+
+				var onCommand telegram.CommandHandler
+				if botCfg.Name == cfg.Telegram.Commands.Bot {
+					onCommand = commandHandler.Handle
+				}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					bot.Listen(ctx, onCommand, commandHandler.HandleFeedback)
+				}()
+			}
 		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.Info("Starting user clients", "count", len(cfg.Telegram.Users))
+			supervisor.Run(ctx)
+		}()
+
+		wg.Wait()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("Waiting for the leader lease", "instance", cfg.Leader.ID)
+		elector.Run(ctx, lead)
 	}()
 
 	<-ctx.Done()
-	log.Println("Shutting down gracefully...")
+	slog.Info("Shutting down gracefully")
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
 	if err := apiServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Error shutting down API server: %v", err)
+		slog.Error("Error shutting down API server", "error", err)
 	}
 
 	wg.Wait()
-	log.Println("Shutdown complete")
+	slog.Info("Shutdown complete")
+}
+
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
 }
 
 func extractMessageText(msg *tg.Message) string {
@@ -165,3 +392,17 @@ func extractMessageText(msg *tg.Message) string {
 	}
 	return msg.Message
 }
+
+type backtestHistory struct {
+	supervisor *telegram.Supervisor
+}
+
+func (h backtestHistory) History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(rules.HistoryMessage) error) error {
+	err := h.supervisor.History(ctx, chat, since, until, limit, func(msg telegram.HistoryMessage) error {
+		return fn(rules.HistoryMessage{Chat: msg.Chat, ID: msg.ID, Date: msg.Date, Message: msg.Message})
+	})
+	if errors.Is(err, telegram.ErrNotConnected) {
+		return rules.ErrBacktestUnavailable
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gabrielmelo/tg-forward/internal/health"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+)
+
+func (s *Server) live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, rules.HealthResponse{Status: string(health.StatusOK)})
+}
+
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	report := s.health.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeHealth(w, status, report)
+}
+
+func writeHealth(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rules.DataResponse{Data: data})
+}
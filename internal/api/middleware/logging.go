@@ -1,22 +1,38 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/logging"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		ctx := r.Context()
+		if id := chimiddleware.GetReqID(ctx); id != "" {
+			ctx = logging.With(ctx, slog.String("request_id", id))
+		}
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
 
-		next.ServeHTTP(w, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
 
-		log.Printf(
-			"%s %s %s",
-			r.Method,
-			r.RequestURI,
-			time.Since(start),
+		slog.InfoContext(ctx, "HTTP request",
+			"method", r.Method,
+			"path", r.RequestURI,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr,
 		)
 	})
 }
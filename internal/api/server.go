@@ -3,24 +3,31 @@ package api
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
-	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/api/middleware"
+	"github.com/gabrielmelo/tg-forward/internal/health"
+	"github.com/gabrielmelo/tg-forward/internal/matches"
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
 	"github.com/gabrielmelo/tg-forward/internal/rules"
 )
 
 type Server struct {
 	service  *rules.Service
+	matches  *matches.Repository
+	health   *health.Checker
 	port     string
 	apiToken string
 	server   *http.Server
 }
 
-func NewServer(svc *rules.Service, port, apiToken string) *Server {
+func NewServer(svc *rules.Service, matchStore *matches.Repository, checker *health.Checker, port, apiToken string) *Server {
 	return &Server{
 		service:  svc,
+		matches:  matchStore,
+		health:   checker,
 		port:     port,
 		apiToken: apiToken,
 	}
@@ -28,6 +35,12 @@ func NewServer(svc *rules.Service, port, apiToken string) *Server {
 
 func (s *Server) Start() error {
 	r := rules.NewRouter(s.service, s.apiToken)
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/health/live", s.live)
+	r.Get("/health/ready", s.ready)
+
+	matchesHandler := matches.NewHandler(s.matches)
+	r.With(middleware.Auth(s.apiToken)).Get("/matches", rules.Wrap(matchesHandler.List))
 
 	addr := fmt.Sprintf(":%s", s.port)
 	s.server = &http.Server{
@@ -35,7 +48,7 @@ func (s *Server) Start() error {
 		Handler: r,
 	}
 
-	log.Printf("Starting API server on %s", addr)
+	slog.Info("Starting API server", "addr", addr)
 	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
 	}
@@ -47,14 +60,10 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		return nil
 	}
 
-	log.Println("Shutting down API server...")
+	slog.Info("Shutting down API server")
 
 	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	return s.server.Shutdown(shutdownCtx)
 }
-
-func (s *Server) GetMatcher() *matcher.Matcher {
-	return s.service.GetMatcher()
-}
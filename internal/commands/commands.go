@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+)
+
+const helpText = `Commands:
+/rules - list rules
+/add <name> <pattern> - add a pattern rule
+/kw <name> <keyword1,keyword2> - add a keyword rule
+/del <id> - remove a rule
+/pause <id> - disable a rule
+/resume <id> - enable a rule
+/test <text> - show which rules match a text`
+
+type RuleService interface {
+	GetRules() []rules.Rule
+	GetRule(id string) (rules.Rule, bool)
+	AddRule(rule rules.Rule) (*rules.Rule, error)
+	RemoveRule(id string) error
+	DisableRule(id string) (*rules.Rule, error)
+	EnableRule(id string) (*rules.Rule, error)
+	SnoozeRule(id string, until time.Time) (*rules.Rule, error)
+	MuteSource(id string, source int64) (*rules.Rule, error)
+	RecordFeedback(ruleID string, kind rules.FeedbackKind, source, userID int64) error
+	TestRules(text string, candidate *rules.Rule) (*rules.TestRulesResponse, error)
+}
+
+type Handler struct {
+	service RuleService
+	admins  []int64
+}
+
+func NewHandler(service RuleService, admins []int64) *Handler {
+	return &Handler{
+		service: service,
+		admins:  admins,
+	}
+}
+
+func (h *Handler) Handle(ctx context.Context, cmd telegram.Command) string {
+	if !slices.Contains(h.admins, cmd.UserID) {
+		slog.WarnContext(ctx, "Ignoring command from unauthorized user", "command", cmd.Name, "user_id", cmd.UserID)
+		return ""
+	}
+
+	switch cmd.Name {
+	case "start", "help":
+		return helpText
+	case "rules":
+		return h.listRules()
+	case "add":
+		return h.addPattern(cmd.Args)
+	case "kw":
+		return h.addKeywords(cmd.Args)
+	case "del":
+		return h.removeRule(cmd.Args)
+	case "pause":
+		return h.setEnabled(cmd.Args, false)
+	case "resume":
+		return h.setEnabled(cmd.Args, true)
+	case "test":
+		return h.testRules(cmd.Args)
+	default:
+		return fmt.Sprintf("Unknown command /%s\n\n%s", cmd.Name, helpText)
+	}
+}
+
+func (h *Handler) listRules() string {
+	list := h.service.GetRules()
+	if len(list) == 0 {
+		return "No rules configured"
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rules (%d):\n", len(list))
+	for i, rule := range list {
+		fmt.Fprintf(&b, "\n%d. %s [%s]\n   %s", i+1, rule.Name, rule.ID, describe(rule))
+		switch {
+		case rule.SnoozedUntil != nil && rule.SnoozedUntil.After(now):
+			fmt.Fprintf(&b, " (snoozed until %s)", rule.SnoozedUntil.UTC().Format("02 Jan 15:04 UTC"))
+		case !rule.Active(now):
+			b.WriteString(" (paused)")
+		}
+	}
+
+	return b.String()
+}
+
+func (h *Handler) addPattern(args string) string {
+	name, pattern, ok := strings.Cut(args, " ")
+	pattern = strings.TrimSpace(pattern)
+	if !ok || name == "" || pattern == "" {
+		return "Usage: /add <name> <pattern>"
+	}
+
+	return h.addRule(rules.Rule{Name: name, Pattern: pattern})
+}
+
+func (h *Handler) addKeywords(args string) string {
+	name, list, ok := strings.Cut(args, " ")
+	if !ok || name == "" {
+		return "Usage: /kw <name> <keyword1,keyword2>"
+	}
+
+	var keywords []string
+	for _, keyword := range strings.Split(list, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	if len(keywords) == 0 {
+		return "Usage: /kw <name> <keyword1,keyword2>"
+	}
+
+	return h.addRule(rules.Rule{Name: name, Keywords: keywords})
+}
+
+func (h *Handler) addRule(rule rules.Rule) string {
+	added, err := h.service.AddRule(rule)
+	if err != nil {
+		return fmt.Sprintf("Failed to add rule: %v", err)
+	}
+
+	slog.Info("Rule added from bot", "rule_id", added.ID, "rule", added.Name)
+	return fmt.Sprintf("Added rule %s [%s]", added.Name, added.ID)
+}
+
+func (h *Handler) removeRule(id string) string {
+	if id == "" {
+		return "Usage: /del <id>"
+	}
+
+	if err := h.service.RemoveRule(id); err != nil {
+		return fmt.Sprintf("Failed to remove rule: %v", err)
+	}
+
+	slog.Info("Rule removed from bot", "rule_id", id)
+	return fmt.Sprintf("Removed rule %s", id)
+}
+
+func (h *Handler) setEnabled(id string, enabled bool) string {
+	if id == "" {
+		if enabled {
+			return "Usage: /resume <id>"
+		}
+		return "Usage: /pause <id>"
+	}
+
+	update := h.service.DisableRule
+	if enabled {
+		update = h.service.EnableRule
+	}
+
+	rule, err := update(id)
+	if err != nil {
+		return fmt.Sprintf("Failed to update rule: %v", err)
+	}
+
+	if enabled {
+		return fmt.Sprintf("Resumed rule %s [%s]", rule.Name, rule.ID)
+	}
+	return fmt.Sprintf("Paused rule %s [%s]", rule.Name, rule.ID)
+}
+
+func (h *Handler) testRules(text string) string {
+	if text == "" {
+		return "Usage: /test <text>"
+	}
+
+	result, err := h.service.TestRules(text, nil)
+	if err != nil {
+		return fmt.Sprintf("Failed to test rules: %v", err)
+	}
+	if len(result.Matched) == 0 {
+		return "No rules matched"
+	}
+
+	names := map[string]string{}
+	for _, match := range result.Matches {
+		names[match.RuleID] = match.RuleName
+	}
+
+	var b strings.Builder
+	b.WriteString("Matched:")
+	for _, id := range result.Matched {
+		fmt.Fprintf(&b, "\n- %s [%s]", names[id], id)
+	}
+	return b.String()
+}
+
+func describe(rule rules.Rule) string {
+	if rule.Pattern != "" {
+		return "pattern: " + rule.Pattern
+	}
+	return "keywords: " + strings.Join(rule.Keywords, ", ")
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeService struct {
+	rules    []rules.Rule
+	feedback []rules.FeedbackKind
+}
+
+func (f *fakeService) GetRules() []rules.Rule {
+	return f.rules
+}
+
+func (f *fakeService) GetRule(id string) (rules.Rule, bool) {
+	for _, rule := range f.rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return rules.Rule{}, false
+}
+
+func (f *fakeService) AddRule(rule rules.Rule) (*rules.Rule, error) {
+	if rule.Pattern == "(" {
+		return nil, fmt.Errorf("invalid regex pattern")
+	}
+	rule.ID = fmt.Sprintf("r%d", len(f.rules)+1)
+	rule.Enabled = true
+	f.rules = append(f.rules, rule)
+	return &rule, nil
+}
+
+func (f *fakeService) RemoveRule(id string) error {
+	for i, rule := range f.rules {
+		if rule.ID == id {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("rule not found: %s", id)
+}
+
+func (f *fakeService) DisableRule(id string) (*rules.Rule, error) {
+	return f.setEnabled(id, false)
+}
+
+func (f *fakeService) EnableRule(id string) (*rules.Rule, error) {
+	return f.setEnabled(id, true)
+}
+
+func (f *fakeService) SnoozeRule(id string, until time.Time) (*rules.Rule, error) {
+	rule, err := f.setEnabled(id, false)
+	if err != nil {
+		return nil, err
+	}
+	rule.SnoozedUntil = &until
+	return rule, nil
+}
+
+func (f *fakeService) MuteSource(id string, source int64) (*rules.Rule, error) {
+	for i := range f.rules {
+		if f.rules[i].ID == id {
+			f.rules[i].MutedSources = append(f.rules[i].MutedSources, source)
+			return &f.rules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("rule not found: %s", id)
+}
+
+func (f *fakeService) RecordFeedback(ruleID string, kind rules.FeedbackKind, source, userID int64) error {
+	f.feedback = append(f.feedback, kind)
+	return nil
+}
+
+func (f *fakeService) setEnabled(id string, enabled bool) (*rules.Rule, error) {
+	for i := range f.rules {
+		if f.rules[i].ID == id {
+			f.rules[i].Enabled = enabled
+			return &f.rules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("rule not found: %s", id)
+}
+
+func (f *fakeService) TestRules(text string, candidate *rules.Rule) (*rules.TestRulesResponse, error) {
+	response := &rules.TestRulesResponse{Matched: []string{}}
+	for _, rule := range f.rules {
+		if rule.Enabled && rule.Pattern == text {
+			response.Matched = append(response.Matched, rule.ID)
+			response.Matches = append(response.Matches, rules.RuleMatch{RuleID: rule.ID, RuleName: rule.Name})
+		}
+	}
+	return response, nil
+}
+
+func TestHandler(t *testing.T) {
+	const admin = 42
+
+	run := func(h *Handler, name, args string) string {
+		return h.Handle(context.Background(), telegram.Command{UserID: admin, Name: name, Args: args})
+	}
+
+	t.Run("should ignore unauthorized users", func(t *testing.T) {
+		h := NewHandler(&fakeService{}, []int64{admin})
+
+		reply := h.Handle(context.Background(), telegram.Command{UserID: 7, Name: "rules"})
+		require.Empty(t, reply)
+	})
+
+	t.Run("should add, pause and remove rules", func(t *testing.T) {
+		service := &fakeService{}
+		h := NewHandler(service, []int64{admin})
+
+		require.Equal(t, "Added rule codes [r1]", run(h, "add", "codes [0-9]{6}"))
+		require.Equal(t, "Added rule deals [r2]", run(h, "kw", "deals promo, sale"))
+		require.Equal(t, []string{"promo", "sale"}, service.rules[1].Keywords)
+
+		require.Equal(t, "Paused rule codes [r1]", run(h, "pause", "r1"))
+		require.False(t, service.rules[0].Enabled)
+
+		list := run(h, "rules", "")
+		require.Contains(t, list, "1. codes [r1]\n   pattern: [0-9]{6} (paused)")
+		require.Contains(t, list, "2. deals [r2]\n   keywords: promo, sale")
+
+		require.Equal(t, "Removed rule r2", run(h, "del", "r2"))
+		require.Len(t, service.rules, 1)
+	})
+
+	t.Run("should report errors and usage", func(t *testing.T) {
+		h := NewHandler(&fakeService{}, []int64{admin})
+
+		require.Equal(t, "Failed to add rule: invalid regex pattern", run(h, "add", "broken ("))
+		require.Equal(t, "Usage: /add <name> <pattern>", run(h, "add", "lonely"))
+		require.Equal(t, "Usage: /kw <name> <keyword1,keyword2>", run(h, "kw", "deals ,"))
+		require.Equal(t, "Failed to remove rule: rule not found: nope", run(h, "del", "nope"))
+		require.Contains(t, run(h, "unknown", ""), "Unknown command /unknown")
+	})
+
+	t.Run("should test text against rules", func(t *testing.T) {
+		service := &fakeService{rules: []rules.Rule{{ID: "r1", Name: "codes", Pattern: "hello", Enabled: true}}}
+		h := NewHandler(service, []int64{admin})
+
+		require.Equal(t, "Matched:\n- codes [r1]", run(h, "test", "hello"))
+		require.Equal(t, "No rules matched", run(h, "test", "bye"))
+	})
+}
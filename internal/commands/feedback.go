@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+)
+
+const (
+	muteDuration   = time.Hour
+	maxAlertLength = 200
+)
+
+func (h *Handler) HandleFeedback(ctx context.Context, feedback telegram.Feedback) string {
+	if !slices.Contains(h.admins, feedback.UserID) {
+		slog.WarnContext(ctx, "Ignoring feedback from unauthorized user", "action", feedback.Action, "user_id", feedback.UserID)
+		return "Not authorized"
+	}
+
+	switch feedback.Action {
+	case telegram.FeedbackMute:
+		rule, err := h.service.SnoozeRule(feedback.RuleID, time.Now().Add(muteDuration))
+		if err != nil {
+			return fmt.Sprintf("Failed to mute rule: %v", err)
+		}
+		h.record(feedback, rules.FeedbackMuted)
+		return fmt.Sprintf("Rule %s muted for 1 hour", rule.Name)
+	case telegram.FeedbackMuteSource:
+		rule, err := h.service.MuteSource(feedback.RuleID, feedback.Source)
+		if err != nil {
+			return fmt.Sprintf("Failed to mute source: %v", err)
+		}
+		h.record(feedback, rules.FeedbackMutedSource)
+		return fmt.Sprintf("Rule %s will ignore this source", rule.Name)
+	case telegram.FeedbackNotRelevant:
+		h.record(feedback, rules.FeedbackNotRelevant)
+		return "Thanks, feedback recorded"
+	case telegram.FeedbackOpen:
+		rule, ok := h.service.GetRule(feedback.RuleID)
+		if !ok {
+			return "Rule not found"
+		}
+		return truncate(fmt.Sprintf("%s [%s]\n%s", rule.Name, rule.ID, describe(rule)), maxAlertLength)
+	default:
+		return ""
+	}
+}
+
+func (h *Handler) record(feedback telegram.Feedback, kind rules.FeedbackKind) {
+	if err := h.service.RecordFeedback(feedback.RuleID, kind, feedback.Source, feedback.UserID); err != nil {
+		slog.Error("Failed to record feedback", "rule_id", feedback.RuleID, "error", err)
+	}
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleFeedback(t *testing.T) {
+	const admin = 42
+
+	setup := func() (*Handler, *fakeService) {
+		service := &fakeService{rules: []rules.Rule{{ID: "r1", Name: "deals", Keywords: []string{"promo"}, Enabled: true}}}
+		return NewHandler(service, []int64{admin}), service
+	}
+
+	feedback := func(action telegram.FeedbackAction, userID int64) telegram.Feedback {
+		return telegram.Feedback{
+			FeedbackRef: telegram.FeedbackRef{RuleID: "r1", Source: 1001},
+			Action:      action,
+			UserID:      userID,
+		}
+	}
+
+	t.Run("should reject unauthorized users", func(t *testing.T) {
+		h, service := setup()
+
+		require.Equal(t, "Not authorized", h.HandleFeedback(context.Background(), feedback(telegram.FeedbackMute, 7)))
+		require.True(t, service.rules[0].Enabled)
+		require.Empty(t, service.feedback)
+	})
+
+	t.Run("should mute the rule for an hour", func(t *testing.T) {
+		h, service := setup()
+
+		require.Equal(t, "Rule deals muted for 1 hour", h.HandleFeedback(context.Background(), feedback(telegram.FeedbackMute, admin)))
+		require.False(t, service.rules[0].Enabled)
+		require.Equal(t, []rules.FeedbackKind{rules.FeedbackMuted}, service.feedback)
+	})
+
+	t.Run("should mute the source for the rule", func(t *testing.T) {
+		h, service := setup()
+
+		require.Equal(t, "Rule deals will ignore this source", h.HandleFeedback(context.Background(), feedback(telegram.FeedbackMuteSource, admin)))
+		require.Equal(t, []int64{1001}, service.rules[0].MutedSources)
+		require.Equal(t, []rules.FeedbackKind{rules.FeedbackMutedSource}, service.feedback)
+	})
+
+	t.Run("should record not relevant feedback", func(t *testing.T) {
+		h, service := setup()
+
+		require.Equal(t, "Thanks, feedback recorded", h.HandleFeedback(context.Background(), feedback(telegram.FeedbackNotRelevant, admin)))
+		require.True(t, service.rules[0].Enabled)
+		require.Equal(t, []rules.FeedbackKind{rules.FeedbackNotRelevant}, service.feedback)
+	})
+
+	t.Run("should describe the rule", func(t *testing.T) {
+		h, service := setup()
+
+		require.Equal(t, "deals [r1]\nkeywords: promo", h.HandleFeedback(context.Background(), feedback(telegram.FeedbackOpen, admin)))
+		require.Empty(t, service.feedback)
+	})
+}
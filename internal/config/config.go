@@ -3,21 +3,38 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
+const DefaultAccount = "default"
+
+var accountName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
 type Config struct {
 	Telegram TelegramConfig
 	API      APIConfig
 	MongoDB  MongoDBConfig
+	Delivery DeliveryConfig
+	Links    LinksConfig
+	Matches  MatchesConfig
+	Tracing  TracingConfig
+	Logging  LoggingConfig
+	Health   HealthConfig
+	Leader   LeaderConfig
 }
 
 type TelegramConfig struct {
-	User UserConfig
-	Bot  BotConfig
+	Users             []UserConfig
+	Bots              []BotConfig
+	Targets           []TargetConfig
+	Commands          CommandsConfig
+	CatchUpMaxMinutes int
 }
 
 type UserConfig struct {
+	Name    string
 	AppID   int
 	AppHash string
 	Phone   string
@@ -25,9 +42,20 @@ type UserConfig struct {
 }
 
 type BotConfig struct {
-	Token          string
-	TargetChatID   int64
-	TargetUsername string
+	Name  string
+	Token string
+}
+
+type TargetConfig struct {
+	Name     string
+	Bot      string
+	ChatID   int64
+	Username string
+}
+
+type CommandsConfig struct {
+	Bot      string
+	AdminIDs []int64
 }
 
 type APIConfig struct {
@@ -40,29 +68,95 @@ type MongoDBConfig struct {
 	Database string
 }
 
+type DeliveryConfig struct {
+	TargetPerMinute int
+	TargetBurst     int
+	MaxQueue        int
+}
+
+type MatchesConfig struct {
+	RetentionDays int
+}
+
+type TracingConfig struct {
+	Enabled     bool
+	ServiceName string
+	SampleRatio float64
+}
+
+type LoggingConfig struct {
+	Level  string
+	Format string
+}
+
+type HealthConfig struct {
+	MaxOutbox int
+}
+
+type LeaderConfig struct {
+	ID           string
+	LeaseSeconds int
+}
+
+type LinksConfig struct {
+	Resolve        bool
+	Shorteners     []string
+	TimeoutSeconds int
+	BudgetSeconds  int
+}
+
 func Load() (*Config, error) {
 	cfg := &Config{}
 
-	appID, err := strconv.Atoi(getEnv("TG_USER_APP_ID", ""))
-	if err != nil {
-		return nil, fmt.Errorf("invalid TG_USER_APP_ID: must be a number")
+	accounts := getEnvList("TG_USER_ACCOUNTS")
+	if len(accounts) == 0 {
+		accounts = []string{DefaultAccount}
+	}
+	for _, name := range accounts {
+		user, err := loadUser(name)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Telegram.Users = append(cfg.Telegram.Users, user)
 	}
-	cfg.Telegram.User.AppID = appID
-	cfg.Telegram.User.AppHash = getEnv("TG_USER_APP_HASH", "")
-	cfg.Telegram.User.Phone = getEnv("TG_USER_PHONE", "")
-	cfg.Telegram.User.Session = getEnv("TG_USER_SESSION", "")
 
-	cfg.Telegram.Bot.Token = getEnv("TG_BOT_TOKEN", "")
+	var err error
+	if cfg.Telegram.CatchUpMaxMinutes, err = getEnvInt("TG_USER_CATCHUP_MAX_MINUTES", 60); err != nil {
+		return nil, err
+	}
 
-	targetChatID := getEnv("TG_BOT_TARGET_CHAT_ID", "")
-	if targetChatID != "" {
-		chatID, err := strconv.ParseInt(targetChatID, 10, 64)
+	bots := getEnvList("TG_BOTS")
+	if len(bots) == 0 {
+		bots = []string{DefaultAccount}
+	}
+	for _, name := range bots {
+		bot, err := loadBot(name)
 		if err != nil {
-			return nil, fmt.Errorf("invalid TG_BOT_TARGET_CHAT_ID: must be a number")
+			return nil, err
 		}
-		cfg.Telegram.Bot.TargetChatID = chatID
+		cfg.Telegram.Bots = append(cfg.Telegram.Bots, bot)
+	}
+
+	targets := getEnvList("TG_TARGETS")
+	if len(targets) == 0 {
+		targets = []string{DefaultAccount}
+	}
+	for _, name := range targets {
+		target, err := loadTarget(name, bots[0])
+		if err != nil {
+			return nil, err
+		}
+		cfg.Telegram.Targets = append(cfg.Telegram.Targets, target)
+	}
+
+	cfg.Telegram.Commands.Bot = getEnv("TG_BOT_COMMANDS", bots[0])
+	for _, id := range getEnvList("TG_BOT_ADMIN_IDS") {
+		adminID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TG_BOT_ADMIN_IDS: %q is not a number", id)
+		}
+		cfg.Telegram.Commands.AdminIDs = append(cfg.Telegram.Commands.AdminIDs, adminID)
 	}
-	cfg.Telegram.Bot.TargetUsername = getEnv("TG_BOT_TARGET_USERNAME", "")
 
 	cfg.API.Port = getEnv("API_PORT", "8080")
 	cfg.API.Token = getEnv("API_TOKEN", "")
@@ -70,6 +164,50 @@ func Load() (*Config, error) {
 	cfg.MongoDB.URI = getEnv("MONGO_URI", "mongodb://localhost:27017")
 	cfg.MongoDB.Database = getEnv("MONGO_DATABASE", "tg-forward")
 
+	if cfg.Delivery.TargetPerMinute, err = getEnvInt("DELIVERY_TARGET_PER_MINUTE", 20); err != nil {
+		return nil, err
+	}
+	if cfg.Delivery.TargetBurst, err = getEnvInt("DELIVERY_TARGET_BURST", 3); err != nil {
+		return nil, err
+	}
+	if cfg.Delivery.MaxQueue, err = getEnvInt("DELIVERY_MAX_QUEUE", 100); err != nil {
+		return nil, err
+	}
+
+	if cfg.Links.Resolve, err = getEnvBool("LINKS_RESOLVE", false); err != nil {
+		return nil, err
+	}
+	cfg.Links.Shorteners = getEnvList("LINKS_SHORTENERS")
+	if cfg.Links.TimeoutSeconds, err = getEnvInt("LINKS_RESOLVE_TIMEOUT", 5); err != nil {
+		return nil, err
+	}
+	if cfg.Links.BudgetSeconds, err = getEnvInt("LINKS_RESOLVE_BUDGET", 10); err != nil {
+		return nil, err
+	}
+
+	if cfg.Matches.RetentionDays, err = getEnvInt("MATCHES_RETENTION_DAYS", 30); err != nil {
+		return nil, err
+	}
+
+	cfg.Tracing.Enabled = getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")) != ""
+	cfg.Tracing.ServiceName = getEnv("OTEL_SERVICE_NAME", "tg-forward")
+	if cfg.Tracing.SampleRatio, err = getEnvFloat("OTEL_TRACES_SAMPLE_RATIO", 1); err != nil {
+		return nil, err
+	}
+
+	if cfg.Health.MaxOutbox, err = getEnvInt("HEALTH_MAX_OUTBOX", 500); err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	cfg.Leader.ID = getEnv("LEADER_ID", hostname)
+	if cfg.Leader.LeaseSeconds, err = getEnvInt("LEADER_LEASE_SECONDS", 15); err != nil {
+		return nil, err
+	}
+
+	cfg.Logging.Level = strings.ToLower(getEnv("LOG_LEVEL", "info"))
+	cfg.Logging.Format = strings.ToLower(getEnv("LOG_FORMAT", "text"))
+
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -77,6 +215,70 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+func loadUser(name string) (UserConfig, error) {
+	user := UserConfig{Name: name}
+
+	appID, err := strconv.Atoi(getEnv(UserEnvKey(name, "APP_ID"), ""))
+	if err != nil {
+		return user, fmt.Errorf("invalid %s: must be a number", UserEnvKey(name, "APP_ID"))
+	}
+	user.AppID = appID
+	user.AppHash = getEnv(UserEnvKey(name, "APP_HASH"), "")
+	user.Phone = getEnv(UserEnvKey(name, "PHONE"), "")
+	user.Session = getEnv(UserEnvKey(name, "SESSION"), "")
+
+	return user, nil
+}
+
+func UserEnvKey(account, key string) string {
+	if account == DefaultAccount {
+		return "TG_USER_" + key
+	}
+	return "TG_USER_" + strings.ToUpper(account) + "_" + key
+}
+
+func loadBot(name string) (BotConfig, error) {
+	bot := BotConfig{Name: name}
+
+	token, err := getEnvSecret(BotEnvKey(name, "TOKEN"))
+	if err != nil {
+		return bot, err
+	}
+	bot.Token = token
+
+	return bot, nil
+}
+
+func loadTarget(name, defaultBot string) (TargetConfig, error) {
+	target := TargetConfig{Name: name}
+
+	if chatID := getEnv(TargetEnvKey(name, "CHAT_ID"), ""); chatID != "" {
+		id, err := strconv.ParseInt(chatID, 10, 64)
+		if err != nil {
+			return target, fmt.Errorf("invalid %s: must be a number", TargetEnvKey(name, "CHAT_ID"))
+		}
+		target.ChatID = id
+	}
+	target.Username = getEnv(TargetEnvKey(name, "USERNAME"), "")
+	target.Bot = getEnv(TargetEnvKey(name, "BOT"), defaultBot)
+
+	return target, nil
+}
+
+func BotEnvKey(bot, key string) string {
+	if bot == DefaultAccount {
+		return "TG_BOT_" + key
+	}
+	return "TG_BOT_" + strings.ToUpper(bot) + "_" + key
+}
+
+func TargetEnvKey(target, key string) string {
+	if target == DefaultAccount {
+		return "TG_BOT_TARGET_" + key
+	}
+	return "TG_TARGET_" + strings.ToUpper(target) + "_" + key
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -84,29 +286,183 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func (c *Config) Validate() error {
-	if c.Telegram.User.AppID == 0 {
-		return fmt.Errorf("telegram.user.app_id is required")
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: must be a number", key)
+	}
+	return n, nil
+}
+
+func getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: must be a number", key)
+	}
+	return f, nil
+}
+
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: must be true or false", key)
+	}
+	return b, nil
+}
+
+func getEnvSecret(key string) (string, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, nil
+	}
+
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
 	}
-	if c.Telegram.User.AppHash == "" {
-		return fmt.Errorf("telegram.user.app_hash is required")
+	return strings.TrimSpace(string(data)), nil
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (c *Config) Validate() error {
+	seen := map[string]bool{}
+	for _, user := range c.Telegram.Users {
+		if seen[user.Name] {
+			return fmt.Errorf("telegram account %q is configured more than once", user.Name)
+		}
+		seen[user.Name] = true
+
+		if !accountName.MatchString(user.Name) {
+			return fmt.Errorf("invalid telegram account name %q: use letters, digits and underscores", user.Name)
+		}
+
+		if user.AppID == 0 {
+			return fmt.Errorf("telegram.user.app_id is required for account %q", user.Name)
+		}
+		if user.AppHash == "" {
+			return fmt.Errorf("telegram.user.app_hash is required for account %q", user.Name)
+		}
+		if user.Phone == "" {
+			return fmt.Errorf("telegram.user.phone is required for account %q", user.Name)
+		}
 	}
-	if c.Telegram.User.Phone == "" {
-		return fmt.Errorf("telegram.user.phone is required")
+
+	bots := map[string]bool{}
+	for _, bot := range c.Telegram.Bots {
+		if bots[bot.Name] {
+			return fmt.Errorf("telegram bot %q is configured more than once", bot.Name)
+		}
+		bots[bot.Name] = true
+
+		if !accountName.MatchString(bot.Name) {
+			return fmt.Errorf("invalid telegram bot name %q: use letters, digits and underscores", bot.Name)
+		}
+		if bot.Token == "" {
+			return fmt.Errorf("telegram.bot.token is required for bot %q", bot.Name)
+		}
 	}
-	if c.Telegram.Bot.Token == "" {
-		return fmt.Errorf("telegram.bot.token is required")
+
+	targets := map[string]bool{}
+	for _, target := range c.Telegram.Targets {
+		if targets[target.Name] {
+			return fmt.Errorf("delivery target %q is configured more than once", target.Name)
+		}
+		targets[target.Name] = true
+
+		if !accountName.MatchString(target.Name) {
+			return fmt.Errorf("invalid delivery target name %q: use letters, digits and underscores", target.Name)
+		}
+		if !bots[target.Bot] {
+			return fmt.Errorf("delivery target %q uses unknown bot %q", target.Name, target.Bot)
+		}
+		if target.ChatID == 0 && target.Username == "" {
+			return fmt.Errorf("either chat_id or username is required for delivery target %q", target.Name)
+		}
 	}
-	if c.Telegram.Bot.TargetChatID == 0 && c.Telegram.Bot.TargetUsername == "" {
-		return fmt.Errorf("either telegram.bot.target_chat_id or telegram.bot.target_username is required")
+
+	if len(c.Telegram.Commands.AdminIDs) > 0 && !bots[c.Telegram.Commands.Bot] {
+		return fmt.Errorf("telegram.bot.commands uses unknown bot %q", c.Telegram.Commands.Bot)
 	}
+
 	if c.API.Token == "" {
 		return fmt.Errorf("api.token is required")
 	}
 
+	if c.Telegram.CatchUpMaxMinutes < 0 {
+		return fmt.Errorf("telegram.user.catchup_max_minutes must not be negative")
+	}
+
 	if c.MongoDB.URI == "" {
 		return fmt.Errorf("mongodb.uri is required")
 	}
 
+	if c.Delivery.TargetPerMinute < 0 || c.Delivery.TargetBurst < 0 || c.Delivery.MaxQueue < 0 {
+		return fmt.Errorf("delivery limits must not be negative")
+	}
+
+	if c.Matches.RetentionDays <= 0 {
+		return fmt.Errorf("matches.retention_days must be greater than zero")
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+
+	if c.Health.MaxOutbox <= 0 {
+		return fmt.Errorf("health.max_outbox must be greater than zero")
+	}
+
+	if c.Leader.ID == "" {
+		return fmt.Errorf("leader.id is required")
+	}
+	if c.Leader.LeaseSeconds < 3 {
+		return fmt.Errorf("leader.lease_seconds must be at least 3")
+	}
+
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging.level must be debug, info, warn or error")
+	}
+
+	if c.Logging.Format != "text" && c.Logging.Format != "json" {
+		return fmt.Errorf("logging.format must be text or json")
+	}
+
+	if c.Links.TimeoutSeconds <= 0 {
+		return fmt.Errorf("links.resolve_timeout must be greater than zero")
+	}
+	if c.Links.BudgetSeconds <= 0 {
+		return fmt.Errorf("links.resolve_budget must be greater than zero")
+	}
+
 	return nil
 }
@@ -0,0 +1,56 @@
+package delivery
+
+import (
+	"math"
+	"time"
+)
+
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+type bucket struct {
+	limit  Limit
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &bucket{
+		limit:  limit,
+		rate:   float64(limit.PerMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+func (b *bucket) ready(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+func (b *bucket) take() {
+	if b == nil {
+		return
+	}
+	b.tokens--
+}
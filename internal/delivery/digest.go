@@ -0,0 +1,204 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+)
+
+const (
+	MaxMessageLength = 4096
+	digestInterval   = 30 * time.Second
+)
+
+func (d *Dispatcher) flushDigests() {
+	if d.store == nil {
+		return
+	}
+
+	pending, err := d.store.Pending()
+	if err != nil {
+		slog.Error("Failed to load pending digests", "error", err)
+		return
+	}
+
+	now := d.now()
+	for ruleID, items := range pending {
+		rule, ok := d.rules.GetRule(ruleID)
+		if !ok {
+			rule = rules.Rule{ID: ruleID, Name: ruleID}
+		}
+
+		if rule.Delivery.IsDigest() {
+			base, err := d.store.LastSent(ruleID)
+			if err != nil {
+				slog.Error("Failed to load digest state", "rule_id", rule.ID, "rule", rule.Name, "error", err)
+				continue
+			}
+			if base.IsZero() {
+				base = items[0].CreatedAt
+			}
+			if now.Before(nextDigestAt(rule.Delivery, base)) {
+				continue
+			}
+		}
+
+		if err := d.sendDigest(rule, items); err != nil {
+			slog.Error("Failed to send digest", "rule_id", rule.ID, "rule", rule.Name, "error", err)
+			continue
+		}
+
+		if err := d.store.MarkSent(ruleID, now); err != nil {
+			slog.Error("Failed to update digest state", "rule_id", rule.ID, "rule", rule.Name, "error", err)
+		}
+	}
+}
+
+func (d *Dispatcher) sendDigest(rule rules.Rule, items []DigestItem) error {
+	loc := time.UTC
+	if rule.Delivery != nil {
+		loc = location(rule.Delivery.Timezone)
+	}
+
+	for _, batch := range digestBatches(rule.Name, items, loc, MaxMessageLength) {
+		for _, chunk := range SplitMessage(formatDigest(rule.Name, batch, loc), MaxMessageLength) {
+			if err := d.sender.SendMessage(context.Background(), rule.Target, chunk, telegram.SendOptions{}); err != nil {
+				return err
+			}
+		}
+
+		if err := d.store.Remove(batch); err != nil {
+			return fmt.Errorf("failed to clear digest: %w", err)
+		}
+		for _, item := range batch {
+			d.report(item.EventIDs, StatusSent, nil)
+		}
+	}
+
+	slog.Info("Digest sent", "rule_id", rule.ID, "rule", rule.Name, "messages", len(items))
+	return nil
+}
+
+func nextDigestAt(schedule *rules.DeliverySchedule, base time.Time) time.Time {
+	loc := location(schedule.Timezone)
+
+	switch schedule.Mode {
+	case rules.DeliveryInterval:
+		return base.Add(time.Duration(schedule.IntervalMinutes) * time.Minute)
+	case rules.DeliveryDaily:
+		at, err := time.Parse("15:04", schedule.At)
+		if err != nil {
+			return base
+		}
+
+		local := base.In(loc)
+		next := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+		if !next.After(local) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		return base
+	}
+}
+
+func formatDigest(name string, items []DigestItem, loc *time.Location) string {
+	var b strings.Builder
+
+	b.WriteString(digestHeader(name, len(items)))
+	for _, item := range items {
+		b.WriteString(digestLine(item, loc))
+	}
+
+	return b.String()
+}
+
+func digestHeader(name string, count int) string {
+	return fmt.Sprintf("Digest for %q: %d messages\n\n", name, count)
+}
+
+func digestLine(item DigestItem, loc *time.Location) string {
+	return fmt.Sprintf("[%s] %s\n\n", item.CreatedAt.In(loc).Format("02 Jan 15:04"), item.Text)
+}
+
+func digestBatches(name string, items []DigestItem, loc *time.Location, limit int) [][]DigestItem {
+	var batches [][]DigestItem
+	var batch []DigestItem
+	size := 0
+
+	for _, item := range items {
+		lineLen := utf16Len(digestLine(item, loc))
+		if len(batch) > 0 && utf16Len(digestHeader(name, len(batch)+1))+size+lineLen > limit {
+			batches = append(batches, batch)
+			batch = nil
+			size = 0
+		}
+		batch = append(batch, item)
+		size += lineLen
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+func SplitMessage(text string, limit int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if chunk := strings.TrimRight(current.String(), "\n"); strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLen := utf16Len(line)
+		if currentLen > 0 && currentLen+lineLen > limit {
+			flush()
+		}
+
+		for lineLen > limit {
+			head, rest := splitUTF16(line, limit)
+			chunks = append(chunks, head)
+			line = rest
+			lineLen = utf16Len(line)
+		}
+
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	flush()
+
+	return chunks
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+func splitUTF16(s string, limit int) (string, string) {
+	n := 0
+	for i, r := range s {
+		size := utf16.RuneLen(r)
+		if n+size > limit {
+			return s[:i], s[i:]
+		}
+		n += size
+	}
+	return s, ""
+}
@@ -0,0 +1,97 @@
+package delivery
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextDigestAt(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	t.Run("should add the interval for interval schedules", func(t *testing.T) {
+		schedule := &rules.DeliverySchedule{Mode: rules.DeliveryInterval, IntervalMinutes: 15}
+
+		require.Equal(t, base.Add(15*time.Minute), nextDigestAt(schedule, base))
+	})
+
+	t.Run("should pick the same day when the daily time is still ahead", func(t *testing.T) {
+		schedule := &rules.DeliverySchedule{Mode: rules.DeliveryDaily, At: "18:00"}
+
+		require.Equal(t, time.Date(2025, 1, 1, 18, 0, 0, 0, time.UTC), nextDigestAt(schedule, base))
+	})
+
+	t.Run("should pick the next day when the daily time has passed", func(t *testing.T) {
+		schedule := &rules.DeliverySchedule{Mode: rules.DeliveryDaily, At: "09:00"}
+
+		require.Equal(t, time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC), nextDigestAt(schedule, base))
+	})
+
+	t.Run("should use the schedule time zone", func(t *testing.T) {
+		schedule := &rules.DeliverySchedule{Mode: rules.DeliveryDaily, At: "09:00", Timezone: "America/Sao_Paulo"}
+
+		next := nextDigestAt(schedule, base)
+
+		require.True(t, next.Equal(time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestDigestBatches(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	item := func(text string) DigestItem {
+		return DigestItem{ID: text, Text: text, CreatedAt: at}
+	}
+
+	t.Run("should keep a small digest in one batch", func(t *testing.T) {
+		items := []DigestItem{item("a"), item("b")}
+
+		require.Equal(t, [][]DigestItem{items}, digestBatches("Deals", items, time.UTC, MaxMessageLength))
+	})
+
+	t.Run("should start a new batch when the message would be too long", func(t *testing.T) {
+		items := []DigestItem{item(strings.Repeat("a", 20)), item(strings.Repeat("b", 20)), item(strings.Repeat("c", 20))}
+		limit := utf16Len(formatDigest("Deals", items[:2], time.UTC))
+
+		batches := digestBatches("Deals", items, time.UTC, limit)
+
+		require.Equal(t, [][]DigestItem{items[:2], items[2:]}, batches)
+		for _, batch := range batches {
+			require.LessOrEqual(t, utf16Len(formatDigest("Deals", batch, time.UTC)), limit)
+		}
+	})
+
+	t.Run("should give an oversized item its own batch", func(t *testing.T) {
+		items := []DigestItem{item("a"), item(strings.Repeat("x", 100)), item("b")}
+
+		batches := digestBatches("Deals", items, time.UTC, 60)
+
+		require.Equal(t, [][]DigestItem{items[:1], items[1:2], items[2:]}, batches)
+	})
+}
+
+func TestSplitMessage(t *testing.T) {
+	t.Run("should keep short messages intact", func(t *testing.T) {
+		require.Equal(t, []string{"hello\nworld"}, SplitMessage("hello\nworld\n", 100))
+	})
+
+	t.Run("should split on line boundaries", func(t *testing.T) {
+		text := strings.Repeat("a", 6) + "\n" + strings.Repeat("b", 6) + "\n"
+
+		require.Equal(t, []string{"aaaaaa", "bbbbbb"}, SplitMessage(text, 10))
+	})
+
+	t.Run("should hard split lines longer than the limit", func(t *testing.T) {
+		chunks := SplitMessage(strings.Repeat("x", 25), 10)
+
+		require.Equal(t, []string{"xxxxxxxxxx", "xxxxxxxxxx", "xxxxx"}, chunks)
+	})
+
+	t.Run("should count characters in UTF-16 code units", func(t *testing.T) {
+		chunks := SplitMessage(strings.Repeat("😀", 6), 4)
+
+		require.Equal(t, []string{"😀😀", "😀😀", "😀😀"}, chunks)
+	})
+}
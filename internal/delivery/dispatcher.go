@@ -0,0 +1,415 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/logging"
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	flushInterval       = time.Second
+	maxDeferredAttempts = 5
+)
+
+var tracer = otel.Tracer("github.com/gabrielmelo/tg-forward/internal/delivery")
+
+type Status string
+
+const (
+	StatusSent       Status = "sent"
+	StatusFailed     Status = "failed"
+	StatusQueued     Status = "queued"
+	StatusCollapsed  Status = "collapsed"
+	StatusDropped    Status = "dropped"
+	StatusDigest     Status = "digest"
+	StatusDeferred   Status = "deferred"
+	StatusSuppressed Status = "suppressed"
+)
+
+type Sender interface {
+	SendMessage(ctx context.Context, target, text string, opts telegram.SendOptions) error
+}
+
+type RuleSource interface {
+	GetRule(id string) (rules.Rule, bool)
+}
+
+type StatusFunc func(eventID string, status Status, err error)
+
+type Dispatcher struct {
+	sender      Sender
+	rules       RuleSource
+	store       *Repository
+	targetLimit Limit
+	maxQueue    int
+	feedback    bool
+	onStatus    StatusFunc
+	now         func() time.Time
+
+	mu      sync.Mutex
+	states  map[string]*ruleState
+	targets map[string]*bucket
+	backlog int
+}
+
+type ruleState struct {
+	name      string
+	target    string
+	limit     rules.RateLimit
+	bucket    *bucket
+	lastSent  time.Time
+	queue     []outgoing
+	collapsed int
+	latest    outgoing
+	events    []string
+}
+
+type outgoing struct {
+	target string
+	text   string
+	opts   telegram.SendOptions
+	span   trace.SpanContext
+	fields []slog.Attr
+	events []string
+}
+
+func NewDispatcher(sender Sender, source RuleSource, store *Repository, targetLimit Limit, maxQueue int) *Dispatcher {
+	return &Dispatcher{
+		sender:      sender,
+		rules:       source,
+		store:       store,
+		targetLimit: targetLimit,
+		maxQueue:    maxQueue,
+		now:         time.Now,
+		states:      map[string]*ruleState{},
+		targets:     map[string]*bucket{},
+	}
+}
+
+func (d *Dispatcher) EnableFeedback() {
+	d.feedback = true
+}
+
+func (d *Dispatcher) OnStatus(fn StatusFunc) {
+	d.onStatus = fn
+}
+
+func (d *Dispatcher) Deliver(ruleIDs []string, text string) error {
+	_, err := d.DeliverMessage(context.Background(), ruleIDs, &matcher.Message{Text: text})
+	return err
+}
+
+func (d *Dispatcher) DeliverMessage(ctx context.Context, ruleIDs []string, message *matcher.Message) (Status, error) {
+	return d.DeliverEvent(ctx, "", ruleIDs, message)
+}
+
+func (d *Dispatcher) DeliverEvent(ctx context.Context, eventID string, ruleIDs []string, message *matcher.Message) (Status, error) {
+	ctx, span := tracer.Start(ctx, "delivery.deliver", trace.WithAttributes(
+		attribute.StringSlice("rule.ids", ruleIDs),
+	))
+	defer span.End()
+
+	status, err := d.deliverMessage(ctx, eventID, ruleIDs, message)
+	span.SetAttributes(attribute.String("delivery.status", string(status)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return status, err
+}
+
+func (d *Dispatcher) deliverMessage(ctx context.Context, eventID string, ruleIDs []string, message *matcher.Message) (Status, error) {
+	rule, ok := d.primaryRule(ctx, ruleIDs)
+	if !ok {
+		return StatusSuppressed, nil
+	}
+
+	ctx = logging.With(ctx, slog.String("rule_id", rule.ID))
+	text := render(ctx, rule, message)
+	msg := outgoing{target: rule.Target, text: text, span: trace.SpanContextFromContext(ctx), fields: logging.Fields(ctx)}
+	if eventID != "" {
+		msg.events = []string{eventID}
+	}
+	if d.feedback {
+		msg.opts.Feedback = &telegram.FeedbackRef{RuleID: rule.ID, Source: message.Chat}
+	}
+
+	if rule.Schedule != nil && !scheduleActive(rule.Schedule, d.now()) {
+		switch rule.Schedule.Outside {
+		case rules.OutsideSilent:
+			msg.opts.DisableNotification = true
+		case rules.OutsideDefer:
+			if d.store != nil {
+				return result(StatusDeferred, d.store.Defer(rule.ID, text, msg.opts, msg.events, nextWindowStart(rule.Schedule, d.now())))
+			}
+		default:
+			slog.InfoContext(ctx, "Rule is outside its active schedule, dropping message", "rule", rule.Name)
+			return StatusDropped, nil
+		}
+	}
+
+	return d.dispatch(ctx, rule, msg)
+}
+
+func (d *Dispatcher) primaryRule(ctx context.Context, ruleIDs []string) (rules.Rule, bool) {
+	if len(ruleIDs) == 0 {
+		return rules.Rule{}, false
+	}
+
+	rule, ok := d.rules.GetRule(ruleIDs[0])
+	if !ok {
+		rule = rules.Rule{ID: ruleIDs[0]}
+	}
+
+	if rule.Action == rules.ActionSuppress {
+		slog.InfoContext(ctx, "Message suppressed", "rule_id", rule.ID, "rule", rule.Name)
+		return rules.Rule{}, false
+	}
+	return rule, true
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, rule rules.Rule, msg outgoing) (Status, error) {
+	if rule.Delivery.IsDigest() && d.store != nil {
+		return result(StatusDigest, d.store.Add(rule.ID, msg.text, msg.events, d.now()))
+	}
+
+	d.mu.Lock()
+	now := d.now()
+	st := d.state(rule, now)
+
+	if len(st.queue) == 0 && st.collapsed == 0 && d.allow(st, now) {
+		d.mu.Unlock()
+		return result(StatusSent, d.sender.SendMessage(ctx, msg.target, msg.text, msg.opts))
+	}
+
+	status := d.overflow(ctx, st, msg)
+	d.updateDepth()
+	d.mu.Unlock()
+
+	return status, nil
+}
+
+func result(status Status, err error) (Status, error) {
+	if err != nil {
+		return StatusFailed, err
+	}
+	return status, nil
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	digestTicker := time.NewTicker(digestInterval)
+	defer digestTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.flush()
+		case <-digestTicker.C:
+			d.flushDeferred()
+			d.flushDigests()
+		}
+	}
+}
+
+func (d *Dispatcher) flush() {
+	d.mu.Lock()
+	now := d.now()
+
+	var pending []outgoing
+	for _, st := range d.states {
+		for len(st.queue) > 0 && d.allow(st, now) {
+			pending = append(pending, st.queue[0])
+			st.queue = st.queue[1:]
+		}
+
+		if st.collapsed > 0 && d.allow(st, now) {
+			pending = append(pending, outgoing{target: st.latest.target, text: collapsedSummary(st), opts: st.latest.opts, span: st.latest.span, fields: st.latest.fields, events: st.events})
+			st.collapsed = 0
+			st.latest = outgoing{}
+			st.events = nil
+		}
+	}
+	d.updateDepth()
+	d.mu.Unlock()
+
+	for _, msg := range pending {
+		ctx := logging.With(trace.ContextWithSpanContext(context.Background(), msg.span), msg.fields...)
+		status, err := result(StatusSent, d.sender.SendMessage(ctx, msg.target, msg.text, msg.opts))
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to forward rate-limited message", "error", err)
+		}
+		d.report(msg.events, status, err)
+	}
+}
+
+func (d *Dispatcher) report(events []string, status Status, err error) {
+	if d.onStatus == nil {
+		return
+	}
+	for _, id := range events {
+		d.onStatus(id, status, err)
+	}
+}
+
+func (d *Dispatcher) flushDeferred() {
+	if d.store == nil {
+		return
+	}
+
+	items, err := d.store.DueDeferred(d.now())
+	if err != nil {
+		slog.Error("Failed to load deferred messages", "error", err)
+		return
+	}
+
+	for _, item := range items {
+		rule, ok := d.rules.GetRule(item.RuleID)
+		if !ok {
+			rule = rules.Rule{ID: item.RuleID}
+		}
+
+		opts := telegram.SendOptions{DisableNotification: item.Silent, Feedback: item.Feedback}
+		status, err := d.dispatch(context.Background(), rule, outgoing{target: rule.Target, text: item.Text, opts: opts, events: item.EventIDs})
+		if err != nil {
+			attempts := item.Attempts + 1
+			if retryAt, ok := deferredRetry(attempts, err, d.now()); ok {
+				slog.Warn("Failed to forward deferred message, retrying", "rule_id", rule.ID, "attempt", attempts, "retry_at", retryAt, "error", err)
+				if err := d.store.RetryDeferred(item.ID, attempts, retryAt); err != nil {
+					slog.Error("Failed to reschedule deferred message", "rule_id", rule.ID, "error", err)
+				}
+				continue
+			}
+			slog.Error("Failed to forward deferred message, giving up", "rule_id", rule.ID, "attempts", attempts, "error", err)
+		}
+		d.report(item.EventIDs, status, err)
+
+		if err := d.store.RemoveDeferred(item.ID); err != nil {
+			slog.Error("Failed to remove deferred message", "rule_id", rule.ID, "error", err)
+		}
+	}
+}
+
+func deferredRetry(attempts int, err error, now time.Time) (time.Time, bool) {
+	if attempts >= maxDeferredAttempts || telegram.IsPermanent(err) {
+		return time.Time{}, false
+	}
+	return now.Add(digestInterval << (attempts - 1)), true
+}
+
+func (d *Dispatcher) state(rule rules.Rule, now time.Time) *ruleState {
+	var limit rules.RateLimit
+	if rule.RateLimit != nil {
+		limit = *rule.RateLimit
+	}
+
+	st, ok := d.states[rule.ID]
+	if !ok {
+		st = &ruleState{}
+		d.states[rule.ID] = st
+	}
+
+	st.name = rule.Name
+	st.target = rule.Target
+	if !ok || st.limit != limit {
+		st.limit = limit
+		st.bucket = nil
+		if limit.PerMinute > 0 {
+			st.bucket = newBucket(Limit{PerMinute: limit.PerMinute, Burst: limit.Burst}, now)
+		}
+	}
+
+	return st
+}
+
+func (d *Dispatcher) allow(st *ruleState, now time.Time) bool {
+	cooldown := time.Duration(st.limit.CooldownSeconds) * time.Second
+	if cooldown > 0 && !st.lastSent.IsZero() && now.Sub(st.lastSent) < cooldown {
+		return false
+	}
+
+	target := d.targetBucket(st.target, now)
+	if !st.bucket.ready(now) || !target.ready(now) {
+		return false
+	}
+
+	st.bucket.take()
+	target.take()
+	st.lastSent = now
+
+	return true
+}
+
+func (d *Dispatcher) targetBucket(target string, now time.Time) *bucket {
+	if d.targetLimit.PerMinute <= 0 {
+		return nil
+	}
+
+	b, ok := d.targets[target]
+	if !ok {
+		b = newBucket(d.targetLimit, now)
+		d.targets[target] = b
+	}
+	return b
+}
+
+func (d *Dispatcher) overflow(ctx context.Context, st *ruleState, msg outgoing) Status {
+	switch st.limit.Overflow {
+	case rules.OverflowDrop:
+		slog.InfoContext(ctx, "Rate limit reached, dropping message", "rule", st.name)
+		return StatusDropped
+	case rules.OverflowCollapse:
+		st.collapsed++
+		st.latest = msg
+		st.events = append(st.events, msg.events...)
+		return StatusCollapsed
+	default:
+		if d.maxQueue > 0 && len(st.queue) >= d.maxQueue {
+			slog.WarnContext(ctx, "Delivery queue full, dropping message", "rule", st.name)
+			return StatusDropped
+		}
+		st.queue = append(st.queue, msg)
+		return StatusQueued
+	}
+}
+
+func (d *Dispatcher) updateDepth() {
+	depth := 0
+	for _, st := range d.states {
+		depth += len(st.queue) + st.collapsed
+	}
+	d.backlog = depth
+	metrics.OutboxDepth.Set(float64(depth))
+}
+
+func (d *Dispatcher) Backlog() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.backlog
+}
+
+func collapsedSummary(st *ruleState) string {
+	return fmt.Sprintf(
+		"%d messages matched rule %q while it was rate limited. Latest:\n\n%s",
+		st.collapsed,
+		st.name,
+		st.latest.text,
+	)
+}
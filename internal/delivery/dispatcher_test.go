@@ -0,0 +1,403 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/logging"
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+	"github.com/gabrielmelo/tg-forward/internal/tracing/tracingtest"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeSender struct {
+	mu       sync.Mutex
+	sent     []string
+	targets  []string
+	silent   []bool
+	contexts []context.Context
+	err      error
+}
+
+func (f *fakeSender) SendMessage(ctx context.Context, target, text string, opts telegram.SendOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, text)
+	f.targets = append(f.targets, target)
+	f.contexts = append(f.contexts, ctx)
+	f.silent = append(f.silent, opts.DisableNotification)
+	return f.err
+}
+
+func (f *fakeSender) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+type fakeRules map[string]rules.Rule
+
+func (f fakeRules) GetRule(id string) (rules.Rule, bool) {
+	rule, ok := f[id]
+	return rule, ok
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func setupDispatcher(t *testing.T, source fakeRules, target Limit) (*Dispatcher, *fakeSender, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	sender := &fakeSender{}
+
+	d := NewDispatcher(sender, source, nil, Limit{}, 10)
+	d.now = clock.Now
+	d.targetLimit = target
+
+	return d, sender, clock
+}
+
+func TestDispatcherRuleRateLimit(t *testing.T) {
+	source := fakeRules{
+		"drop":     {ID: "drop", Name: "Drop", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowDrop}},
+		"queue":    {ID: "queue", Name: "Queue", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowQueue}},
+		"collapse": {ID: "collapse", Name: "Collapse", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowCollapse}},
+	}
+
+	t.Run("should drop messages over the limit", func(t *testing.T) {
+		d, sender, clock := setupDispatcher(t, source, Limit{})
+
+		require.NoError(t, d.Deliver([]string{"drop"}, "first"))
+		require.NoError(t, d.Deliver([]string{"drop"}, "second"))
+
+		clock.Advance(time.Minute)
+		d.flush()
+
+		require.Equal(t, []string{"first"}, sender.messages())
+	})
+
+	t.Run("should queue messages over the limit and send them later", func(t *testing.T) {
+		d, sender, clock := setupDispatcher(t, source, Limit{})
+
+		require.NoError(t, d.Deliver([]string{"queue"}, "first"))
+		require.NoError(t, d.Deliver([]string{"queue"}, "second"))
+		require.NoError(t, d.Deliver([]string{"queue"}, "third"))
+		require.Equal(t, []string{"first"}, sender.messages())
+
+		clock.Advance(time.Minute)
+		d.flush()
+		require.Equal(t, []string{"first", "second"}, sender.messages())
+
+		clock.Advance(time.Minute)
+		d.flush()
+		require.Equal(t, []string{"first", "second", "third"}, sender.messages())
+	})
+
+	t.Run("should collapse messages over the limit into a summary", func(t *testing.T) {
+		d, sender, clock := setupDispatcher(t, source, Limit{})
+
+		require.NoError(t, d.Deliver([]string{"collapse"}, "first"))
+		require.NoError(t, d.Deliver([]string{"collapse"}, "second"))
+		require.NoError(t, d.Deliver([]string{"collapse"}, "third"))
+
+		clock.Advance(time.Minute)
+		d.flush()
+
+		sent := sender.messages()
+		require.Len(t, sent, 2)
+		require.Contains(t, sent[1], "2 messages matched rule \"Collapse\"")
+		require.Contains(t, sent[1], "third")
+	})
+}
+
+func TestDispatcherAsyncStatus(t *testing.T) {
+	source := fakeRules{
+		"queue":    {ID: "queue", Name: "Queue", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowQueue}},
+		"collapse": {ID: "collapse", Name: "Collapse", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowCollapse}},
+	}
+	d, sender, clock := setupDispatcher(t, source, Limit{})
+
+	var updates []string
+	d.OnStatus(func(eventID string, status Status, err error) {
+		updates = append(updates, eventID+":"+string(status))
+	})
+
+	deliver := func(eventID, ruleID string) Status {
+		status, err := d.DeliverEvent(context.Background(), eventID, []string{ruleID}, &matcher.Message{Text: eventID})
+		require.NoError(t, err)
+		return status
+	}
+
+	require.Equal(t, StatusSent, deliver("q1", "queue"))
+	require.Equal(t, StatusQueued, deliver("q2", "queue"))
+	require.Equal(t, StatusQueued, deliver("q3", "queue"))
+	require.Equal(t, StatusSent, deliver("c1", "collapse"))
+	require.Equal(t, StatusCollapsed, deliver("c2", "collapse"))
+	require.Equal(t, StatusCollapsed, deliver("c3", "collapse"))
+	require.Empty(t, updates)
+
+	clock.Advance(time.Minute)
+	d.flush()
+	require.ElementsMatch(t, []string{"q2:sent", "c2:sent", "c3:sent"}, updates)
+
+	updates = nil
+	sender.err = errors.New("chat not found")
+	clock.Advance(time.Minute)
+	d.flush()
+	require.Equal(t, []string{"q3:failed"}, updates)
+}
+
+func TestDispatcherCooldown(t *testing.T) {
+	source := fakeRules{
+		"cool": {ID: "cool", Name: "Cool", RateLimit: &rules.RateLimit{CooldownSeconds: 30, Overflow: rules.OverflowDrop}},
+	}
+	d, sender, clock := setupDispatcher(t, source, Limit{})
+
+	require.NoError(t, d.Deliver([]string{"cool"}, "first"))
+	clock.Advance(10 * time.Second)
+	require.NoError(t, d.Deliver([]string{"cool"}, "second"))
+	clock.Advance(30 * time.Second)
+	require.NoError(t, d.Deliver([]string{"cool"}, "third"))
+
+	require.Equal(t, []string{"first", "third"}, sender.messages())
+}
+
+func TestDispatcherTargetRateLimit(t *testing.T) {
+	source := fakeRules{
+		"a": {ID: "a", Name: "A"},
+		"b": {ID: "b", Name: "B"},
+	}
+	d, sender, clock := setupDispatcher(t, source, Limit{PerMinute: 60, Burst: 1})
+
+	require.NoError(t, d.Deliver([]string{"a"}, "from a"))
+	require.NoError(t, d.Deliver([]string{"b"}, "from b"))
+	require.Equal(t, []string{"from a"}, sender.messages())
+
+	clock.Advance(time.Second)
+	d.flush()
+	require.Equal(t, []string{"from a", "from b"}, sender.messages())
+}
+
+func TestDispatcherTargets(t *testing.T) {
+	source := fakeRules{
+		"a": {ID: "a", Name: "A", Target: "alerts"},
+		"b": {ID: "b", Name: "B", Target: "news"},
+		"c": {ID: "c", Name: "C"},
+	}
+	d, sender, _ := setupDispatcher(t, source, Limit{PerMinute: 60, Burst: 1})
+
+	require.NoError(t, d.Deliver([]string{"a"}, "from a"))
+	require.NoError(t, d.Deliver([]string{"b"}, "from b"))
+	require.NoError(t, d.Deliver([]string{"c"}, "from c"))
+
+	require.Equal(t, []string{"from a", "from b", "from c"}, sender.messages())
+	require.Equal(t, []string{"alerts", "news", ""}, sender.targets)
+}
+
+func TestDispatcherStatus(t *testing.T) {
+	source := fakeRules{
+		"limited":  {ID: "limited", Name: "Limited", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowQueue}},
+		"suppress": {ID: "suppress", Name: "Suppress", Action: rules.ActionSuppress},
+	}
+	d, _, _ := setupDispatcher(t, source, Limit{})
+
+	deliver := func(ruleIDs ...string) Status {
+		status, err := d.DeliverMessage(context.Background(), ruleIDs, &matcher.Message{Text: "hello"})
+		require.NoError(t, err)
+		return status
+	}
+
+	require.Equal(t, StatusSent, deliver("limited"))
+	require.Equal(t, StatusQueued, deliver("limited"))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.OutboxDepth))
+	require.Equal(t, 1, d.Backlog())
+	require.Equal(t, StatusSuppressed, deliver("suppress", "limited"))
+}
+
+func TestDispatcherActiveSchedule(t *testing.T) {
+	window := []rules.ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00"}}
+	source := fakeRules{
+		"drop":   {ID: "drop", Name: "Drop", Schedule: &rules.ActiveSchedule{Windows: window, Outside: rules.OutsideDrop}},
+		"silent": {ID: "silent", Name: "Silent", Schedule: &rules.ActiveSchedule{Windows: window, Outside: rules.OutsideSilent}},
+	}
+
+	t.Run("should deliver normally inside the window", func(t *testing.T) {
+		d, sender, _ := setupDispatcher(t, source, Limit{})
+
+		require.NoError(t, d.Deliver([]string{"silent"}, "inside"))
+
+		require.Equal(t, []string{"inside"}, sender.messages())
+		require.Equal(t, []bool{false}, sender.silent)
+	})
+
+	t.Run("should drop messages outside the window", func(t *testing.T) {
+		d, sender, clock := setupDispatcher(t, source, Limit{})
+		clock.Advance(8 * time.Hour)
+
+		require.NoError(t, d.Deliver([]string{"drop"}, "outside"))
+
+		require.Empty(t, sender.messages())
+	})
+
+	t.Run("should send silently outside the window", func(t *testing.T) {
+		d, sender, clock := setupDispatcher(t, source, Limit{})
+		clock.Advance(8 * time.Hour)
+
+		require.NoError(t, d.Deliver([]string{"silent"}, "outside"))
+
+		require.Equal(t, []string{"outside"}, sender.messages())
+		require.Equal(t, []bool{true}, sender.silent)
+	})
+}
+
+func TestDispatcherSuppression(t *testing.T) {
+	source := fakeRules{
+		"suppress": {ID: "suppress", Name: "Suppress", Action: rules.ActionSuppress},
+		"forward":  {ID: "forward", Name: "Forward"},
+	}
+	d, sender, _ := setupDispatcher(t, source, Limit{})
+
+	require.NoError(t, d.Deliver([]string{"suppress", "forward"}, "suppressed"))
+	require.NoError(t, d.Deliver([]string{"forward", "suppress"}, "forwarded"))
+
+	require.Equal(t, []string{"forwarded"}, sender.messages())
+}
+
+func TestDispatcherTemplate(t *testing.T) {
+	source := fakeRules{
+		"deals": {ID: "deals", Name: "Deals", Template: "{{.Rule}}: {{.Link}}"},
+	}
+	d, sender, _ := setupDispatcher(t, source, Limit{})
+
+	status, err := d.DeliverMessage(context.Background(), []string{"deals"}, &matcher.Message{
+		Text: "Promo https://amzn.to/abc",
+		URLs: []string{"https://www.amazon.com.br/dp/123?utm_source=tg&tag=abc"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, status)
+
+	require.Equal(t, []string{"Deals: https://www.amazon.com.br/dp/123?tag=abc"}, sender.messages())
+}
+
+func TestDispatcherMediaWithoutText(t *testing.T) {
+	source := fakeRules{
+		"photos": {ID: "photos", Name: "Photos"},
+	}
+	d, sender, _ := setupDispatcher(t, source, Limit{})
+
+	status, err := d.DeliverMessage(context.Background(), []string{"photos"}, &matcher.Message{Media: "photo"})
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, status)
+
+	require.Equal(t, []string{"[photo]"}, sender.messages())
+}
+
+func TestDispatcherTracing(t *testing.T) {
+	spans := tracingtest.Record(t)
+
+	source := fakeRules{
+		"limited": {ID: "limited", Name: "Limited", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowQueue}},
+	}
+	d, sender, clock := setupDispatcher(t, source, Limit{})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "telegram.message")
+	_, err := d.DeliverMessage(ctx, []string{"limited"}, &matcher.Message{Text: "first"})
+	require.NoError(t, err)
+	_, err = d.DeliverMessage(ctx, []string{"limited"}, &matcher.Message{Text: "second"})
+	require.NoError(t, err)
+	parent.End()
+
+	clock.Advance(time.Minute)
+	d.flush()
+
+	traceID := parent.SpanContext().TraceID()
+	require.Len(t, sender.contexts, 2)
+	for _, sent := range sender.contexts {
+		require.Equal(t, traceID, trace.SpanContextFromContext(sent).TraceID())
+	}
+
+	var statuses []string
+	for _, span := range spans.GetSpans() {
+		if span.Name != "delivery.deliver" {
+			continue
+		}
+		require.Equal(t, traceID, span.SpanContext.TraceID())
+		for _, attr := range span.Attributes {
+			if attr.Key == "delivery.status" {
+				statuses = append(statuses, attr.Value.AsString())
+			}
+		}
+	}
+	require.Equal(t, []string{"sent", "queued"}, statuses)
+}
+
+func TestDispatcherCorrelation(t *testing.T) {
+	source := fakeRules{
+		"limited": {ID: "limited", Name: "Limited", RateLimit: &rules.RateLimit{PerMinute: 1, Overflow: rules.OverflowQueue}},
+	}
+	d, sender, clock := setupDispatcher(t, source, Limit{})
+
+	ctx := logging.WithCorrelationID(context.Background(), "abc123")
+	_, err := d.DeliverMessage(ctx, []string{"limited"}, &matcher.Message{Text: "first"})
+	require.NoError(t, err)
+	_, err = d.DeliverMessage(ctx, []string{"limited"}, &matcher.Message{Text: "second"})
+	require.NoError(t, err)
+
+	clock.Advance(time.Minute)
+	d.flush()
+
+	require.Len(t, sender.contexts, 2)
+	for _, sent := range sender.contexts {
+		require.Equal(t, "abc123", logging.CorrelationID(sent))
+	}
+}
+
+func TestDeferredRetry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should back off transient errors", func(t *testing.T) {
+		retryAt, ok := deferredRetry(1, errors.New("network down"), now)
+		require.True(t, ok)
+		require.Equal(t, now.Add(digestInterval), retryAt)
+
+		retryAt, ok = deferredRetry(3, errors.New("network down"), now)
+		require.True(t, ok)
+		require.Equal(t, now.Add(4*digestInterval), retryAt)
+	})
+
+	t.Run("should give up after the last attempt", func(t *testing.T) {
+		_, ok := deferredRetry(maxDeferredAttempts, errors.New("network down"), now)
+		require.False(t, ok)
+	})
+
+	t.Run("should give up on permanent errors", func(t *testing.T) {
+		err := fmt.Errorf("failed to send message: %w", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was kicked"})
+		_, ok := deferredRetry(1, err, now)
+		require.False(t, ok)
+
+		err = fmt.Errorf("failed to send message: %w", &tgbotapi.Error{Code: 429, Message: "Too Many Requests"})
+		_, ok = deferredRetry(1, err, now)
+		require.True(t, ok)
+	})
+}
@@ -0,0 +1,228 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/telegram"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DigestItem struct {
+	ID        string    `bson:"_id"`
+	RuleID    string    `bson:"rule_id"`
+	Text      string    `bson:"text"`
+	EventIDs  []string  `bson:"event_ids,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type digestState struct {
+	RuleID     string    `bson:"_id"`
+	LastSentAt time.Time `bson:"last_sent_at"`
+}
+
+type DeferredItem struct {
+	ID        string                `bson:"_id"`
+	RuleID    string                `bson:"rule_id"`
+	Text      string                `bson:"text"`
+	Silent    bool                  `bson:"silent,omitempty"`
+	Feedback  *telegram.FeedbackRef `bson:"feedback,omitempty"`
+	EventIDs  []string              `bson:"event_ids,omitempty"`
+	DeliverAt time.Time             `bson:"deliver_at"`
+	Attempts  int                   `bson:"attempts,omitempty"`
+}
+
+type Repository struct {
+	items    *mongo.Collection
+	states   *mongo.Collection
+	deferred *mongo.Collection
+}
+
+func NewRepository(client *mongo.Client, database string) (*Repository, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := client.Database(database)
+	items := db.Collection("digest_items")
+
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "rule_id", Value: 1}, {Key: "created_at", Value: 1}},
+	}
+	if _, err := items.Indexes().CreateOne(ctx, indexModel); err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	deferred := db.Collection("deferred_messages")
+
+	deferredIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "deliver_at", Value: 1}},
+	}
+	if _, err := deferred.Indexes().CreateOne(ctx, deferredIndex); err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	return &Repository{
+		items:    items,
+		states:   db.Collection("digest_states"),
+		deferred: deferred,
+	}, nil
+}
+
+func (r *Repository) Add(ruleID, text string, eventIDs []string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	item := DigestItem{
+		ID:        uuid.New().String(),
+		RuleID:    ruleID,
+		Text:      text,
+		EventIDs:  eventIDs,
+		CreatedAt: at,
+	}
+
+	if _, err := r.items.InsertOne(ctx, item); err != nil {
+		return fmt.Errorf("failed to insert digest item: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) Pending() (map[string][]DigestItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.items.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find digest items: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var items []DigestItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode digest items: %w", err)
+	}
+
+	pending := map[string][]DigestItem{}
+	for _, item := range items {
+		pending[item.RuleID] = append(pending[item.RuleID], item)
+	}
+
+	return pending, nil
+}
+
+func (r *Repository) Remove(items []DigestItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	if _, err := r.items.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return fmt.Errorf("failed to delete digest items: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) LastSent(ruleID string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var state digestState
+	err := r.states.FindOne(ctx, bson.M{"_id": ruleID}).Decode(&state)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to find digest state: %w", err)
+	}
+
+	return state.LastSentAt, nil
+}
+
+func (r *Repository) MarkSent(ruleID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.states.UpdateOne(
+		ctx,
+		bson.M{"_id": ruleID},
+		bson.M{"$set": bson.M{"last_sent_at": at}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update digest state: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) Defer(ruleID, text string, opts telegram.SendOptions, eventIDs []string, deliverAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	item := DeferredItem{
+		ID:        uuid.New().String(),
+		RuleID:    ruleID,
+		Text:      text,
+		Silent:    opts.DisableNotification,
+		Feedback:  opts.Feedback,
+		EventIDs:  eventIDs,
+		DeliverAt: deliverAt,
+	}
+
+	if _, err := r.deferred.InsertOne(ctx, item); err != nil {
+		return fmt.Errorf("failed to insert deferred message: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DueDeferred(now time.Time) ([]DeferredItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "deliver_at", Value: 1}})
+	cursor, err := r.deferred.Find(ctx, bson.M{"deliver_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deferred messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var items []DeferredItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode deferred messages: %w", err)
+	}
+
+	return items, nil
+}
+
+func (r *Repository) RemoveDeferred(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := r.deferred.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("failed to delete deferred message: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RetryDeferred(id string, attempts int, deliverAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"attempts": attempts, "deliver_at": deliverAt}}
+	if _, err := r.deferred.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return fmt.Errorf("failed to reschedule deferred message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+)
+
+func scheduleActive(schedule *rules.ActiveSchedule, t time.Time) bool {
+	local := t.In(location(schedule.Timezone))
+
+	for _, window := range schedule.Windows {
+		if windowActive(window, local) {
+			return true
+		}
+	}
+	return false
+}
+
+func windowActive(window rules.ScheduleWindow, local time.Time) bool {
+	start, end := clockMinutes(window.Start), clockMinutes(window.End)
+	now := local.Hour()*60 + local.Minute()
+
+	if start < end {
+		return onDay(window, local.Weekday()) && now >= start && now < end
+	}
+
+	if now >= start && onDay(window, local.Weekday()) {
+		return true
+	}
+	return now < end && onDay(window, local.AddDate(0, 0, -1).Weekday())
+}
+
+func nextWindowStart(schedule *rules.ActiveSchedule, t time.Time) time.Time {
+	loc := location(schedule.Timezone)
+	local := t.In(loc)
+
+	var next time.Time
+	for offset := 0; offset <= 7; offset++ {
+		day := local.AddDate(0, 0, offset)
+		for _, window := range schedule.Windows {
+			if !onDay(window, day.Weekday()) {
+				continue
+			}
+
+			minutes := clockMinutes(window.Start)
+			start := time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, loc)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+
+	return t.Add(24 * time.Hour)
+}
+
+func onDay(window rules.ScheduleWindow, weekday time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, day := range window.Days {
+		if rules.Weekdays[strings.ToLower(day)] == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func clockMinutes(value string) int {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+func location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
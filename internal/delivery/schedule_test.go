@@ -0,0 +1,57 @@
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleActive(t *testing.T) {
+	weekdays := &rules.ActiveSchedule{
+		Timezone: "America/Sao_Paulo",
+		Windows:  []rules.ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00"}},
+	}
+	overnight := &rules.ActiveSchedule{
+		Windows: []rules.ScheduleWindow{{Days: []string{"fri"}, Start: "22:00", End: "02:00"}},
+	}
+
+	tests := []struct {
+		name     string
+		schedule *rules.ActiveSchedule
+		at       time.Time
+		want     bool
+	}{
+		{"inside business hours", weekdays, time.Date(2025, 1, 6, 13, 0, 0, 0, time.UTC), true},
+		{"before business hours in local time", weekdays, time.Date(2025, 1, 6, 11, 59, 0, 0, time.UTC), false},
+		{"on the weekend", weekdays, time.Date(2025, 1, 4, 15, 0, 0, 0, time.UTC), false},
+		{"overnight window before midnight", overnight, time.Date(2025, 1, 3, 23, 0, 0, 0, time.UTC), true},
+		{"overnight window after midnight", overnight, time.Date(2025, 1, 4, 1, 0, 0, 0, time.UTC), true},
+		{"overnight window on the wrong day", overnight, time.Date(2025, 1, 5, 1, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, scheduleActive(tt.schedule, tt.at))
+		})
+	}
+}
+
+func TestNextWindowStart(t *testing.T) {
+	schedule := &rules.ActiveSchedule{
+		Windows: []rules.ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00"}},
+	}
+
+	t.Run("should pick the same day before the window", func(t *testing.T) {
+		at := time.Date(2025, 1, 6, 7, 0, 0, 0, time.UTC)
+
+		require.Equal(t, time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), nextWindowStart(schedule, at))
+	})
+
+	t.Run("should skip the weekend", func(t *testing.T) {
+		at := time.Date(2025, 1, 3, 19, 0, 0, 0, time.UTC)
+
+		require.Equal(t, time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), nextWindowStart(schedule, at))
+	})
+}
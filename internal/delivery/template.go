@@ -0,0 +1,63 @@
+package delivery
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"github.com/gabrielmelo/tg-forward/internal/links"
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+)
+
+type templateData struct {
+	Text   string
+	Rule   string
+	Sender string
+	Media  string
+	Link   string
+	Links  []string
+}
+
+func render(ctx context.Context, rule rules.Rule, msg *matcher.Message) string {
+	if rule.Template == "" {
+		return plainText(msg)
+	}
+
+	tmpl, err := template.New(rule.ID).Parse(rule.Template)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid rule template", "rule", rule.Name, "error", err)
+		return plainText(msg)
+	}
+
+	data := templateData{
+		Text:   msg.Text,
+		Rule:   rule.Name,
+		Sender: msg.Sender.Username,
+		Media:  msg.Media,
+	}
+	for _, u := range msg.URLs {
+		data.Links = append(data.Links, links.Clean(u))
+	}
+	if len(data.Links) > 0 {
+		data.Link = data.Links[0]
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		slog.WarnContext(ctx, "Failed to render rule template", "rule", rule.Name, "error", err)
+		return plainText(msg)
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return plainText(msg)
+	}
+	return b.String()
+}
+
+func plainText(msg *matcher.Message) string {
+	if msg.Text == "" && msg.Media != "" {
+		return "[" + msg.Media + "]"
+	}
+	return msg.Text
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusDown Status = "down"
+)
+
+var ErrTimeout = errors.New("check timed out")
+
+type Check func(ctx context.Context) error
+
+type Component struct {
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     Status               `json:"status"`
+	Components map[string]Component `json:"components"`
+	Info       map[string]string    `json:"info,omitempty"`
+}
+
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  []Check
+	info    map[string]func() string
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, info: map[string]func() string{}}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+}
+
+func (c *Checker) AddInfo(name string, value func() string) {
+	c.info[name] = value
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	errs := make([]error, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Components: map[string]Component{}}
+	for i, name := range c.names {
+		if errs[i] != nil {
+			report.Status = StatusDown
+			report.Components[name] = Component{Status: StatusDown, Error: errs[i].Error()}
+			continue
+		}
+		report.Components[name] = Component{Status: StatusOK}
+	}
+
+	if len(c.info) > 0 {
+		report.Info = make(map[string]string, len(c.info))
+		for name, value := range c.info {
+			report.Info[name] = value()
+		}
+	}
+
+	return report
+}
+
+func run(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrTimeout
+	}
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	t.Run("should report ok when every check passes", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Add("mongo", func(ctx context.Context) error { return nil })
+		checker.Add("outbox", func(ctx context.Context) error { return nil })
+
+		report := checker.Check(context.Background())
+
+		require.Equal(t, StatusOK, report.Status)
+		require.Equal(t, map[string]Component{
+			"mongo":  {Status: StatusOK},
+			"outbox": {Status: StatusOK},
+		}, report.Components)
+	})
+
+	t.Run("should report down with the failing component", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Add("mongo", func(ctx context.Context) error { return nil })
+		checker.Add("user:default", func(ctx context.Context) error { return errors.New("not connected") })
+
+		report := checker.Check(context.Background())
+
+		require.Equal(t, StatusDown, report.Status)
+		require.Equal(t, Component{Status: StatusOK}, report.Components["mongo"])
+		require.Equal(t, Component{Status: StatusDown, Error: "not connected"}, report.Components["user:default"])
+	})
+
+	t.Run("should include info values", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.AddInfo("role", func() string { return "follower" })
+
+		report := checker.Check(context.Background())
+
+		require.Equal(t, StatusOK, report.Status)
+		require.Equal(t, map[string]string{"role": "follower"}, report.Info)
+	})
+
+	t.Run("should fail checks that do not finish in time", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		checker := NewChecker(10 * time.Millisecond)
+		checker.Add("bot:default", func(ctx context.Context) error {
+			<-block
+			return nil
+		})
+
+		report := checker.Check(context.Background())
+
+		require.Equal(t, StatusDown, report.Status)
+		require.Equal(t, ErrTimeout.Error(), report.Components["bot:default"].Error)
+	})
+}
@@ -0,0 +1,102 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+const minTrigrams = 8
+
+var profiles = map[string][]string{
+	"en": {
+		" th", "the", "he ", "ing", "nd ", " an", "and", " in", "ng ", "er ",
+		" to", "to ", "ed ", " of", "of ", "ion", "at ", "on ", "tio", "ent",
+		"is ", "es ", " is", "re ", " ha", "in ", "for", " fo", "hat", "tha",
+		" wi", "ll ", "ere", "ou ", "you", " yo", " it", "it ", " be", "or ",
+	},
+	"pt": {
+		" de", "de ", "os ", " qu", "que", "ue ", "as ", "ão ", " co", "do ",
+		"ent", " se", "da ", "ção", "nte", " pa", "ara", "par", "com", "om ",
+		" a ", "ra ", "to ", " e ", "em ", " do", " da", "não", " nã", "men",
+		" pr", "ado", " um", "um ", "uma", "ões", "est", "nha", "lho", "ma ",
+	},
+	"es": {
+		" de", "de ", "os ", " la", "la ", "el ", " el", "que", " qu", "ue ",
+		"en ", "as ", " en", "es ", " co", "ión", "ado", "ent", " se", "ón ",
+		"del", "los", " lo", "por", " po", "ara", " pa", "con", "nte", " y ",
+		"una", " un", "ien", "est", " mu", "año", "ño ", " es", "aci", "cia",
+	},
+	"fr": {
+		" de", "es ", "de ", " le", "le ", "ent", "nt ", " la", "la ", "ion",
+		" et", "et ", "re ", " pa", "les", " co", " qu", "que", "ue ", "ne ",
+		" un", "our", "ous", " po", "ur ", "des", "est", " es", " en", "en ",
+		"ait", "tio", "men", " ce", "pas", " ne", "une", "ans", " vo", "vou",
+	},
+	"de": {
+		"en ", "er ", "ch ", "der", " de", "ie ", "ich", "ein", " ei", "sch",
+		"die", " di", "und", " un", "nd ", "cht", "te ", " da", "den", "in ",
+		"ine", "gen", " zu", "ung", " ge", "es ", "ter", "ist", " is", " ve",
+		"nic", "ht ", "ber", "das", "eit", " au", "auf", " mi", "mit", "st ",
+	},
+	"it": {
+		" di", "di ", "la ", " la", "to ", "che", " ch", "he ", "re ", "ell",
+		"lla", " de", "del", "ent", "on ", " co", "one", "ato", " in", "no ",
+		" il", "il ", "ion", "per", " pe", "ere", " un", "are", "zio", "nte",
+		"ta ", "con", "non", " no", "gli", " gl", "ssi", "ett", "tto", "ame",
+	},
+}
+
+func Supported() []string {
+	return []string{"de", "en", "es", "fr", "it", "pt"}
+}
+
+func Detect(text string) string {
+	trigrams := extractTrigrams(text)
+	if len(trigrams) < minTrigrams {
+		return ""
+	}
+
+	best, bestScore, secondScore := "", 0, 0
+	for _, lang := range Supported() {
+		score := 0
+		for rank, trigram := range profiles[lang] {
+			score += trigrams[trigram] * (len(profiles[lang]) - rank)
+		}
+
+		if score > bestScore {
+			best, secondScore, bestScore = lang, bestScore, score
+		} else if score > secondScore {
+			secondScore = score
+		}
+	}
+
+	if bestScore == 0 || bestScore == secondScore {
+		return ""
+	}
+	return best
+}
+
+func extractTrigrams(text string) map[string]int {
+	var b strings.Builder
+	b.WriteRune(' ')
+	space := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteRune(' ')
+			space = true
+		}
+	}
+	if !space {
+		b.WriteRune(' ')
+	}
+
+	runes := []rune(b.String())
+	trigrams := map[string]int{}
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams[string(runes[i:i+3])]++
+	}
+	return trigrams
+}
@@ -0,0 +1,32 @@
+package language_test
+
+import (
+	"testing"
+
+	"github.com/gabrielmelo/tg-forward/internal/language"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"The new release is available for everyone and you can download it from the website", "en"},
+		{"A nova versão já está disponível para todos e você pode baixar no site oficial", "pt"},
+		{"La nueva versión ya está disponible para todos y puedes descargarla desde el sitio", "es"},
+		{"La nouvelle version est disponible pour tous et vous pouvez la télécharger sur le site", "fr"},
+		{"Die neue Version ist ab sofort für alle verfügbar und kann auf der Webseite geladen werden", "de"},
+		{"La nuova versione è disponibile per tutti e puoi scaricarla dal sito ufficiale", "it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			require.Equal(t, tt.expected, language.Detect(tt.text))
+		})
+	}
+
+	t.Run("should not guess on short texts", func(t *testing.T) {
+		require.Empty(t, language.Detect("ok"))
+	})
+}
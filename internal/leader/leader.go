@@ -0,0 +1,228 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collection = "leases"
+
+type leaseStore interface {
+	acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error)
+	release(ctx context.Context, name, holder string) error
+}
+
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type Elector struct {
+	store  leaseStore
+	name   string
+	holder string
+	ttl    time.Duration
+	clock  clock
+	leader atomic.Bool
+}
+
+func NewElector(client *mongo.Client, database, name, holder string, ttl time.Duration) *Elector {
+	return &Elector{
+		store:  mongoStore{leases: client.Database(database).Collection(collection)},
+		name:   name,
+		holder: holder,
+		ttl:    ttl,
+		clock:  realClock{},
+	}
+}
+
+func (e *Elector) ID() string {
+	return e.holder
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	renewInterval := e.ttl / 4
+	leadFor := e.ttl / 2
+	stopTimeout := e.ttl / 4
+
+	var (
+		cancel   context.CancelFunc
+		done     chan struct{}
+		deadline time.Time
+	)
+
+	finished := func() bool {
+		if done == nil {
+			return true
+		}
+		select {
+		case <-done:
+			done = nil
+			return true
+		default:
+			return false
+		}
+	}
+
+	start := func() {
+		var leadCtx context.Context
+		leadCtx, cancel = context.WithCancel(ctx)
+		done = make(chan struct{})
+		e.setLeader(true)
+
+		go func(done chan struct{}) {
+			defer close(done)
+			lead(leadCtx)
+		}(done)
+	}
+
+	stop := func() {
+		if cancel != nil {
+			cancel()
+			cancel = nil
+			e.setLeader(false)
+		}
+		if done == nil {
+			return
+		}
+
+		select {
+		case <-done:
+			done = nil
+		case <-e.clock.After(stopTimeout):
+			slog.Error("Leader work did not stop in time, not taking the lease again until it does", "instance", e.holder, "timeout", stopTimeout)
+		}
+	}
+
+	defer func() {
+		stop()
+		if done == nil {
+			e.release()
+		}
+	}()
+
+	for {
+		if cancel != nil || finished() {
+			now := e.clock.Now()
+			acquired, err := e.acquire(ctx, now, renewInterval)
+
+			switch {
+			case acquired:
+				deadline = now.Add(leadFor)
+				if cancel == nil {
+					slog.InfoContext(ctx, "Acquired leader lease", "instance", e.holder, "lease", e.name)
+					start()
+				}
+			case err != nil && cancel != nil:
+				slog.WarnContext(ctx, "Failed to renew leader lease, retrying", "instance", e.holder, "error", err)
+			case err != nil && ctx.Err() == nil:
+				slog.ErrorContext(ctx, "Failed to acquire leader lease", "instance", e.holder, "error", err)
+			case err == nil && cancel != nil:
+				slog.WarnContext(ctx, "Leader lease taken by another instance, stepping down", "instance", e.holder, "lease", e.name)
+				stop()
+			}
+		}
+
+		if cancel != nil && !e.clock.Now().Before(deadline) {
+			slog.WarnContext(ctx, "Could not renew leader lease in time, stepping down", "instance", e.holder, "lease", e.name)
+			stop()
+		}
+
+		wait := renewInterval
+		if cancel != nil {
+			wait = min(wait, deadline.Sub(e.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.clock.After(wait):
+		}
+	}
+}
+
+func (e *Elector) acquire(ctx context.Context, now time.Time, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return e.store.acquire(ctx, e.name, e.holder, now, e.ttl)
+}
+
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := e.store.release(ctx, e.name, e.holder); err != nil {
+		slog.Error("Failed to release leader lease", "instance", e.holder, "error", err)
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.leader.Store(leader)
+
+	if leader {
+		metrics.Leader.Set(1)
+		metrics.LeaderTransitions.WithLabelValues("acquired").Inc()
+		return
+	}
+	metrics.Leader.Set(0)
+	metrics.LeaderTransitions.WithLabelValues("lost").Inc()
+}
+
+type mongoStore struct {
+	leases *mongo.Collection
+}
+
+func (s mongoStore) acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"holder":     holder,
+			"expires_at": now.Add(ttl),
+		},
+	}
+
+	_, err := s.leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return true, nil
+}
+
+func (s mongoStore) release(ctx context.Context, name, holder string) error {
+	if _, err := s.leases.DeleteOne(ctx, bson.M{"_id": name, "holder": holder}); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testTTL = 300 * time.Millisecond
+
+func leading(flag *atomic.Bool) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		flag.Store(true)
+		<-ctx.Done()
+		flag.Store(false)
+	}
+}
+
+func TestElector(t *testing.T) {
+	client, database, cleanup := testutils.SetupTestDB(t)
+	defer cleanup()
+
+	t.Run("should elect a single leader and fail over when it stops", func(t *testing.T) {
+		first := NewElector(client, database, "failover", "first", testTTL)
+		second := NewElector(client, database, "failover", "second", testTTL)
+
+		var firstLeading, secondLeading atomic.Bool
+
+		firstCtx, stopFirst := context.WithCancel(context.Background())
+		firstDone := make(chan struct{})
+		go func() {
+			defer close(firstDone)
+			first.Run(firstCtx, leading(&firstLeading))
+		}()
+		require.Eventually(t, firstLeading.Load, 5*time.Second, 10*time.Millisecond)
+		require.True(t, first.IsLeader())
+
+		secondCtx, stopSecond := context.WithCancel(context.Background())
+		defer stopSecond()
+		go second.Run(secondCtx, leading(&secondLeading))
+		require.Never(t, secondLeading.Load, 3*testTTL, 10*time.Millisecond)
+		require.False(t, second.IsLeader())
+
+		stopFirst()
+		<-firstDone
+		require.False(t, firstLeading.Load())
+		require.False(t, first.IsLeader())
+
+		require.Eventually(t, secondLeading.Load, 5*time.Second, 10*time.Millisecond)
+		require.True(t, second.IsLeader())
+	})
+
+	t.Run("should take over an expired lease", func(t *testing.T) {
+		_, err := client.Database(database).Collection(collection).InsertOne(context.Background(), bson.M{
+			"_id":        "expiry",
+			"holder":     "crashed",
+			"expires_at": time.Now().Add(2 * testTTL),
+		})
+		require.NoError(t, err)
+
+		elector := NewElector(client, database, "expiry", "standby", testTTL)
+
+		var standbyLeading atomic.Bool
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go elector.Run(ctx, leading(&standbyLeading))
+
+		require.Never(t, standbyLeading.Load, testTTL, 10*time.Millisecond)
+		require.Eventually(t, standbyLeading.Load, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	afters  int
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.afters++
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func (c *fakeClock) Afters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.afters
+}
+
+type acquireResult struct {
+	acquired bool
+	err      error
+}
+
+type fakeStore struct {
+	mu       sync.Mutex
+	results  []acquireResult
+	acquires []time.Time
+	released int
+}
+
+func (s *fakeStore) acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.results[min(len(s.acquires), len(s.results)-1)]
+	s.acquires = append(s.acquires, now)
+	return result.acquired, result.err
+}
+
+func (s *fakeStore) release(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.released++
+	return nil
+}
+
+func (s *fakeStore) Acquires() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.acquires)
+}
+
+func (s *fakeStore) Released() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.released
+}
+
+func TestElectorTiming(t *testing.T) {
+	const ttl = 4 * time.Second
+
+	waitAfters := func(t *testing.T, clock *fakeClock, n int) {
+		require.Eventually(t, func() bool { return clock.Afters() == n }, time.Second, time.Millisecond)
+	}
+
+	t.Run("should keep leading through failed renewals and step down half a lease before expiry", func(t *testing.T) {
+		clock := newFakeClock()
+		start := clock.Now()
+		store := &fakeStore{results: []acquireResult{{acquired: true}, {err: errors.New("timeout")}}}
+		elector := &Elector{store: store, name: "lease", holder: "first", ttl: ttl, clock: clock}
+
+		stopped := make(chan time.Time, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go elector.Run(ctx, func(ctx context.Context) {
+			<-ctx.Done()
+			stopped <- clock.Now()
+		})
+
+		waitAfters(t, clock, 1)
+		require.True(t, elector.IsLeader())
+
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 2)
+		require.Equal(t, 2, store.Acquires())
+		require.True(t, elector.IsLeader())
+
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 4)
+		require.False(t, elector.IsLeader())
+		require.Equal(t, start.Add(ttl/2), <-stopped)
+	})
+
+	t.Run("should step down when the lease is lost and not compete until the work stops", func(t *testing.T) {
+		clock := newFakeClock()
+		store := &fakeStore{results: []acquireResult{{acquired: true}, {acquired: false}, {acquired: true}}}
+		elector := &Elector{store: store, name: "lease", holder: "first", ttl: ttl, clock: clock}
+
+		var runs atomic.Int32
+		unblock := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go elector.Run(ctx, func(ctx context.Context) {
+			if runs.Add(1) == 1 {
+				<-unblock
+				return
+			}
+			<-ctx.Done()
+		})
+
+		waitAfters(t, clock, 1)
+		require.True(t, elector.IsLeader())
+
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 2)
+		require.False(t, elector.IsLeader())
+
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 3)
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 4)
+		require.Equal(t, 2, store.Acquires())
+
+		close(unblock)
+		require.Eventually(t, func() bool {
+			clock.Advance(ttl / 4)
+			return elector.IsLeader()
+		}, time.Second, time.Millisecond)
+		require.EqualValues(t, 2, runs.Load())
+	})
+
+	t.Run("should stop waiting for work that ignores ctx and release the lease once it returns", func(t *testing.T) {
+		clock := newFakeClock()
+		store := &fakeStore{results: []acquireResult{{acquired: true}, {err: errors.New("timeout")}}}
+		elector := &Elector{store: store, name: "lease", holder: "first", ttl: ttl, clock: clock}
+
+		unblock := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			elector.Run(ctx, func(ctx context.Context) {
+				<-unblock
+			})
+		}()
+
+		waitAfters(t, clock, 1)
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 2)
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 3)
+		require.False(t, elector.IsLeader())
+
+		clock.Advance(ttl / 4)
+		waitAfters(t, clock, 4)
+		require.Equal(t, 3, store.Acquires())
+
+		clock.Advance(ttl)
+		waitAfters(t, clock, 5)
+		require.Equal(t, 3, store.Acquires())
+
+		close(unblock)
+		cancel()
+		<-done
+		require.Equal(t, 1, store.Released())
+	})
+
+	t.Run("should not release the lease while the work is still running", func(t *testing.T) {
+		clock := newFakeClock()
+		store := &fakeStore{results: []acquireResult{{acquired: true}}}
+		elector := &Elector{store: store, name: "lease", holder: "first", ttl: ttl, clock: clock}
+
+		unblock := make(chan struct{})
+		defer close(unblock)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			elector.Run(ctx, func(ctx context.Context) {
+				<-unblock
+			})
+		}()
+
+		waitAfters(t, clock, 1)
+		cancel()
+		waitAfters(t, clock, 2)
+		clock.Advance(ttl / 4)
+		<-done
+
+		require.False(t, elector.IsLeader())
+		require.Zero(t, store.Released())
+	})
+}
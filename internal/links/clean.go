@@ -0,0 +1,39 @@
+package links
+
+import (
+	"net/url"
+	"strings"
+)
+
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "ref": true,
+	"ref_": true, "smid": true, "spm": true, "si": true,
+}
+
+func Clean(raw string) string {
+	u, err := url.Parse(normalize(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return u.String()
+}
+
+func Host(raw string) string {
+	u, err := url.Parse(normalize(raw))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
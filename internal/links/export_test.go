@@ -0,0 +1,5 @@
+package links
+
+func AllowPrivateAddresses(r *Resolver) {
+	r.allowPrivate = true
+}
@@ -0,0 +1,118 @@
+package links_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/links"
+	"github.com/stretchr/testify/require"
+)
+
+func newResolver(client *http.Client, shorteners []string) *links.Resolver {
+	resolver := links.NewResolver(client, shorteners)
+	links.AllowPrivateAddresses(resolver)
+	return resolver
+}
+
+func TestResolver(t *testing.T) {
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Redirect(w, r, "/middle", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/middle", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/dp/123?utm_source=tg&tag=abc", http.StatusFound)
+	})
+	mux.HandleFunc("/dp/123", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		http.Redirect(w, r, "/dp/123", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("should follow redirects to the final url", func(t *testing.T) {
+		resolver := newResolver(server.Client(), nil)
+
+		resolved := resolver.Resolve(context.Background(), server.URL+"/short")
+
+		require.Equal(t, server.URL+"/dp/123?utm_source=tg&tag=abc", resolved)
+	})
+
+	t.Run("should cache resolved urls", func(t *testing.T) {
+		hits.Store(0)
+		resolver := newResolver(server.Client(), nil)
+
+		resolver.Resolve(context.Background(), server.URL+"/short")
+		resolver.Resolve(context.Background(), server.URL+"/short")
+
+		require.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("should only resolve known shorteners", func(t *testing.T) {
+		hits.Store(0)
+		resolver := newResolver(server.Client(), []string{"bit.ly"})
+
+		resolved := resolver.Resolve(context.Background(), server.URL+"/short")
+
+		require.Equal(t, server.URL+"/short", resolved)
+		require.Zero(t, hits.Load())
+	})
+
+	t.Run("should keep the original url when resolution fails", func(t *testing.T) {
+		resolver := newResolver(server.Client(), nil)
+
+		resolved := resolver.Resolve(context.Background(), "http://127.0.0.1:1/unreachable")
+
+		require.Equal(t, "http://127.0.0.1:1/unreachable", resolved)
+	})
+
+	t.Run("should not cache failed resolutions", func(t *testing.T) {
+		resolver := newResolver(server.Client(), nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.Equal(t, server.URL+"/short", resolver.Resolve(ctx, server.URL+"/short"))
+		require.Equal(t, server.URL+"/dp/123?utm_source=tg&tag=abc", resolver.Resolve(context.Background(), server.URL+"/short"))
+	})
+
+	t.Run("should not connect to private addresses", func(t *testing.T) {
+		hits.Store(0)
+		resolver := links.NewResolver(server.Client(), nil)
+
+		resolved := resolver.Resolve(context.Background(), server.URL+"/short")
+
+		require.Equal(t, server.URL+"/short", resolved)
+		require.Zero(t, hits.Load())
+	})
+
+	t.Run("should stop resolving when the budget runs out", func(t *testing.T) {
+		resolver := newResolver(server.Client(), nil)
+		resolver.SetBudget(50 * time.Millisecond)
+
+		start := time.Now()
+		resolved := resolver.ResolveAll(context.Background(), []string{server.URL + "/slow", server.URL + "/slow"})
+
+		require.Equal(t, []string{server.URL + "/slow", server.URL + "/slow"}, resolved)
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+func TestClean(t *testing.T) {
+	require.Equal(t,
+		"https://www.amazon.com.br/dp/123?tag=abc",
+		links.Clean("https://www.amazon.com.br/dp/123?utm_source=tg&utm_medium=social&tag=abc&fbclid=xyz#reviews"),
+	)
+	require.Equal(t, "https://example.com/item", links.Clean("https://example.com/item?ref=home"))
+	require.Equal(t, "http://example.com/", links.Clean("example.com/"))
+}
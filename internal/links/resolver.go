@@ -0,0 +1,235 @@
+package links
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	cacheTTL      = 24 * time.Hour
+	cacheSize     = 10000
+	maxRedirects  = 10
+	defaultBudget = 10 * time.Second
+	dialTimeout   = 5 * time.Second
+)
+
+var (
+	errPrivateAddress = errors.New("refusing to connect to a non-public address")
+
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+var DefaultShorteners = []string{
+	"amzn.to", "a.co", "bit.ly", "buff.ly", "cutt.ly", "goo.gl", "is.gd",
+	"ow.ly", "rebrand.ly", "shorturl.at", "t.co", "tinyurl.com", "s.click.aliexpress.com",
+	"shope.ee", "tidd.ly", "lnkd.in",
+}
+
+type Resolver struct {
+	client       *http.Client
+	shorteners   []string
+	budget       time.Duration
+	allowPrivate bool
+
+	mu    sync.Mutex
+	cache map[string]*list.Element
+	order *list.List
+}
+
+type cacheEntry struct {
+	key     string
+	url     string
+	expires time.Time
+}
+
+func NewResolver(client *http.Client, shorteners []string) *Resolver {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	r := &Resolver{
+		shorteners: shorteners,
+		budget:     defaultBudget,
+		cache:      map[string]*list.Element{},
+		order:      list.New(),
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t, ok := client.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout, Control: r.checkAddress}
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	resolverClient := *client
+	resolverClient.Transport = transport
+	resolverClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	r.client = &resolverClient
+
+	return r
+}
+
+func (r *Resolver) checkAddress(network, address string, _ syscall.RawConn) error {
+	if r.allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !public(ip) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, ip)
+	}
+	return nil
+}
+
+func public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+func (r *Resolver) SetBudget(budget time.Duration) {
+	r.budget = budget
+}
+
+func (r *Resolver) ResolveAll(ctx context.Context, urls []string) []string {
+	if r == nil || len(urls) == 0 {
+		return urls
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.budget)
+	defer cancel()
+
+	resolved := make([]string, len(urls))
+	for i, u := range urls {
+		if ctx.Err() != nil {
+			resolved[i] = u
+			continue
+		}
+		resolved[i] = r.Resolve(ctx, u)
+	}
+	return resolved
+}
+
+func (r *Resolver) Resolve(ctx context.Context, raw string) string {
+	target := normalize(raw)
+
+	u, err := url.Parse(target)
+	if err != nil || !r.shortened(u.Hostname()) {
+		return raw
+	}
+
+	if cached, ok := r.cached(target); ok {
+		return cached
+	}
+
+	final := r.follow(ctx, target)
+	if final == "" {
+		return raw
+	}
+
+	r.store(target, final)
+	return final
+}
+
+func (r *Resolver) cached(target string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elem, ok := r.cache[target]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		r.order.Remove(elem)
+		delete(r.cache, target)
+		return "", false
+	}
+	r.order.MoveToFront(elem)
+	return entry.url, true
+}
+
+func (r *Resolver) store(target, final string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expires := time.Now().Add(cacheTTL)
+	if elem, ok := r.cache[target]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.url, entry.expires = final, expires
+		r.order.MoveToFront(elem)
+		return
+	}
+
+	r.cache[target] = r.order.PushFront(&cacheEntry{key: target, url: final, expires: expires})
+	for r.order.Len() > cacheSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.cache, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (r *Resolver) follow(ctx context.Context, target string) string {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return ""
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		return resp.Request.URL.String()
+	}
+	return ""
+}
+
+func (r *Resolver) shortened(host string) bool {
+	if len(r.shorteners) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(host)
+	for _, shortener := range r.shorteners {
+		if host == shortener || strings.HasSuffix(host, "."+shortener) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	return raw
+}
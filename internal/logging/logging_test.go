@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should add context fields to records", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := New(&buf, "json", "info")
+		require.NoError(t, err)
+
+		ctx := WithCorrelationID(context.Background(), "abc123")
+		ctx = With(ctx, slog.Int64("chat_id", 1001))
+		logger.InfoContext(ctx, "Message forwarded", "rule_id", "deals")
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		require.Equal(t, "Message forwarded", record["msg"])
+		require.Equal(t, "abc123", record["correlation_id"])
+		require.Equal(t, 1001.0, record["chat_id"])
+		require.Equal(t, "deals", record["rule_id"])
+	})
+
+	t.Run("should filter records below the level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := New(&buf, "text", "warn")
+		require.NoError(t, err)
+
+		logger.Info("hidden")
+		require.Empty(t, buf.String())
+
+		logger.Warn("shown")
+		require.Contains(t, buf.String(), "level=WARN msg=shown")
+	})
+
+	t.Run("should reject unknown formats and levels", func(t *testing.T) {
+		_, err := New(&bytes.Buffer{}, "xml", "info")
+		require.Error(t, err)
+
+		_, err = New(&bytes.Buffer{}, "text", "verbose")
+		require.Error(t, err)
+	})
+}
+
+func TestCorrelationID(t *testing.T) {
+	ctx := context.Background()
+	require.Empty(t, CorrelationID(ctx))
+
+	parent := WithCorrelationID(ctx, "first")
+	child := With(parent, slog.String("rule_id", "deals"))
+	require.Equal(t, "first", CorrelationID(child))
+	require.Len(t, Fields(parent), 1)
+	require.Len(t, NewCorrelationID(), 16)
+}
+
+func TestZap(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	lg := Zap(logger.With("account", "main")).Named("updates").With(zap.Int64("channel_id", 42))
+	lg.Debug("Gap accepted")
+	require.Empty(t, buf.String())
+
+	lg.Warn("Failed to get difference", zap.Error(errors.New("FLOOD_WAIT")), zap.Int("pts", 7))
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "Failed to get difference", record["msg"])
+	require.Equal(t, "main", record["account"])
+	require.Equal(t, "updates", record["logger"])
+	require.Equal(t, 42.0, record["channel_id"])
+	require.Equal(t, 7.0, record["pts"])
+	require.Equal(t, "FLOOD_WAIT", record["error"])
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type zapCore struct {
+	logger *slog.Logger
+}
+
+func Zap(logger *slog.Logger) *zap.Logger {
+	return zap.New(zapCore{logger})
+}
+
+func (c zapCore) Enabled(level zapcore.Level) bool {
+	return c.logger.Enabled(context.Background(), slogLevel(level))
+}
+
+func (c zapCore) With(fields []zapcore.Field) zapcore.Core {
+	return zapCore{c.logger.With(zapAttrs(fields)...)}
+}
+
+func (c zapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c zapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	args := zapAttrs(fields)
+	if entry.LoggerName != "" {
+		args = append(args, slog.String("logger", entry.LoggerName))
+	}
+	c.logger.Log(context.Background(), slogLevel(entry.Level), entry.Message, args...)
+	return nil
+}
+
+func (c zapCore) Sync() error {
+	return nil
+}
+
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level < zapcore.InfoLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func zapAttrs(fields []zapcore.Field) []any {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	args := make([]any, 0, len(enc.Fields))
+	for _, key := range slices.Sorted(maps.Keys(enc.Fields)) {
+		args = append(args, slog.Any(key, enc.Fields[key]))
+	}
+	return args
+}
@@ -1,26 +1,83 @@
 package matcher
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/text/runes"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 )
 
+var tracer = otel.Tracer("github.com/gabrielmelo/tg-forward/internal/matcher")
+
 type MatchRule struct {
-	Pattern  string
-	Keywords []string
+	ID             string
+	Pattern        string
+	Keywords       []string
+	StopProcessing bool
+	Conditions     []Condition
+}
+
+type Message struct {
+	Account string
+	Chat    int64
+	Text    string
+	Sender  Sender
+	Media   string
+	URLs    []string
+}
+
+type Sender struct {
+	ID                    int64
+	Username              string
+	Bot                   bool
+	Premium               bool
+	Verified              bool
+	ForwardedFromID       int64
+	ForwardedFromUsername string
+	IsAdmin               func() bool
+}
+
+type Condition func(msg *Message) bool
+
+type Explanation struct {
+	Normalized string
+	Matched    []string
+	Rules      []RuleExplanation
+}
+
+type RuleExplanation struct {
+	ID            string
+	Spans         []Span
+	ConditionsMet bool
+}
+
+type Span struct {
+	Start int
+	End   int
 }
 
 type Matcher struct {
+	ids            []string
+	stops          []bool
+	conditions     [][]Condition
 	patterns       []*regexp.Regexp
 	keywordMatches [][]string
 }
 
 func New(rules []MatchRule) (*Matcher, error) {
+	ids := make([]string, 0)
+	stops := make([]bool, 0)
+	conditions := make([][]Condition, 0)
 	patterns := make([]*regexp.Regexp, 0)
 	keywords := make([][]string, 0)
 
@@ -30,15 +87,24 @@ func New(rules []MatchRule) (*Matcher, error) {
 			if err != nil {
 				return nil, err
 			}
+			ids = append(ids, rule.ID)
+			stops = append(stops, rule.StopProcessing)
+			conditions = append(conditions, rule.Conditions)
 			patterns = append(patterns, re)
 			keywords = append(keywords, nil)
 		} else if len(rule.Keywords) > 0 {
+			ids = append(ids, rule.ID)
+			stops = append(stops, rule.StopProcessing)
+			conditions = append(conditions, rule.Conditions)
 			patterns = append(patterns, nil)
 			keywords = append(keywords, rule.Keywords)
 		}
 	}
 
 	return &Matcher{
+		ids:            ids,
+		stops:          stops,
+		conditions:     conditions,
 		patterns:       patterns,
 		keywordMatches: keywords,
 	}, nil
@@ -63,38 +129,150 @@ func normalizeText(text string) string {
 }
 
 func (m *Matcher) Match(text string) bool {
+	defer prometheus.NewTimer(metrics.MatchDuration).ObserveDuration()
+
+	msg := &Message{Text: text}
 	normalized := normalizeText(text)
 
 	for i := range m.patterns {
-		if m.patterns[i] != nil {
-			if m.patterns[i].MatchString(normalized) {
-				return true
-			}
-		} else if m.keywordMatches[i] != nil {
-			if matchesAllKeywords(normalized, m.keywordMatches[i]) {
-				return true
-			}
+		if m.matchesAt(i, normalized) && matchesAllConditions(msg, m.conditions[i]) {
+			return true
 		}
 	}
 	return false
 }
 
 func (m *Matcher) FindMatches(text string) []string {
+	msg := &Message{Text: text}
 	normalized := normalizeText(text)
 	var matches []string
 
 	for i := range m.patterns {
+		if !m.matchesAt(i, normalized) || !matchesAllConditions(msg, m.conditions[i]) {
+			continue
+		}
 		if m.patterns[i] != nil {
-			if m.patterns[i].MatchString(normalized) {
-				matches = append(matches, m.patterns[i].String())
+			matches = append(matches, m.patterns[i].String())
+		} else {
+			matches = append(matches, strings.Join(m.keywordMatches[i], ", "))
+		}
+	}
+	return matches
+}
+
+func (m *Matcher) MatchingRules(text string) []string {
+	return m.MatchMessage(&Message{Text: text})
+}
+
+func (m *Matcher) MatchMessageContext(ctx context.Context, msg *Message) []string {
+	_, span := tracer.Start(ctx, "matcher.match", trace.WithAttributes(
+		attribute.Int("matcher.rules", len(m.ids)),
+	))
+	defer span.End()
+
+	ids := m.MatchMessage(msg)
+	span.SetAttributes(attribute.StringSlice("matcher.matched", ids))
+	return ids
+}
+
+func (m *Matcher) MatchMessage(msg *Message) []string {
+	defer prometheus.NewTimer(metrics.MatchDuration).ObserveDuration()
+
+	normalized := normalizeText(msg.Text)
+	var ids []string
+
+	for i := range m.patterns {
+		if m.matchesAt(i, normalized) && matchesAllConditions(msg, m.conditions[i]) {
+			ids = append(ids, m.ids[i])
+			if m.stops[i] {
+				break
 			}
-		} else if m.keywordMatches[i] != nil {
-			if matchesAllKeywords(normalized, m.keywordMatches[i]) {
-				matches = append(matches, strings.Join(m.keywordMatches[i], ", "))
+		}
+	}
+	return ids
+}
+
+func (m *Matcher) Explain(msg *Message) Explanation {
+	normalized := normalizeText(msg.Text)
+	explanation := Explanation{Normalized: normalized}
+	stopped := false
+
+	for i := range m.patterns {
+		if !m.matchesAt(i, normalized) {
+			continue
+		}
+
+		rule := RuleExplanation{
+			ID:            m.ids[i],
+			Spans:         m.spansAt(i, normalized),
+			ConditionsMet: matchesAllConditions(msg, m.conditions[i]),
+		}
+		explanation.Rules = append(explanation.Rules, rule)
+
+		if rule.ConditionsMet && !stopped {
+			explanation.Matched = append(explanation.Matched, m.ids[i])
+			stopped = m.stops[i]
+		}
+	}
+	return explanation
+}
+
+func (m *Matcher) spansAt(i int, normalized string) []Span {
+	var spans []Span
+	if m.patterns[i] != nil {
+		for _, loc := range m.patterns[i].FindAllStringIndex(normalized, -1) {
+			spans = append(spans, runeSpan(normalized, loc[0], loc[1]))
+		}
+		return spans
+	}
+
+	for _, keyword := range m.keywordMatches[i] {
+		normalizedKeyword := normalizeText(keyword)
+		if normalizedKeyword == "" {
+			continue
+		}
+		for offset := 0; ; {
+			idx := strings.Index(normalized[offset:], normalizedKeyword)
+			if idx < 0 {
+				break
 			}
+			start := offset + idx
+			end := start + len(normalizedKeyword)
+			spans = append(spans, runeSpan(normalized, start, end))
+			offset = end
 		}
 	}
-	return matches
+	return spans
+}
+
+func runeSpan(text string, start, end int) Span {
+	return Span{
+		Start: utf8.RuneCountInString(text[:start]),
+		End:   utf8.RuneCountInString(text[:end]),
+	}
+}
+
+func (m *Matcher) matchesAt(i int, normalized string) bool {
+	if m.patterns[i] != nil {
+		return m.patterns[i].MatchString(normalized)
+	}
+	if m.keywordMatches[i] != nil {
+		return matchesAllKeywords(normalized, m.keywordMatches[i])
+	}
+	return false
+}
+
+func matchesAllConditions(msg *Message, conditions []Condition) bool {
+	for _, condition := range conditions {
+		if !condition(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Sender) Admin() bool {
+	return s.IsAdmin != nil && s.IsAdmin()
 }
 
 func matchesAllKeywords(text string, keywords []string) bool {
@@ -0,0 +1,118 @@
+package matcher_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/tracing/tracingtest"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestMatchingRules(t *testing.T) {
+	t.Run("should return every matching rule in order", func(t *testing.T) {
+		m, err := matcher.New([]matcher.MatchRule{
+			{ID: "first", Pattern: "promo"},
+			{ID: "second", Keywords: []string{"promo", "today"}},
+			{ID: "third", Pattern: "unrelated"},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"first", "second"}, m.MatchingRules("Promo válida today"))
+	})
+
+	t.Run("should stop after a matching rule with stop processing", func(t *testing.T) {
+		m, err := matcher.New([]matcher.MatchRule{
+			{ID: "skipped", Pattern: "nothing"},
+			{ID: "stopper", Pattern: "promo", StopProcessing: true},
+			{ID: "generic", Pattern: "promo"},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"stopper"}, m.MatchingRules("promo"))
+	})
+
+	t.Run("should keep evaluating when the stopping rule does not match", func(t *testing.T) {
+		m, err := matcher.New([]matcher.MatchRule{
+			{ID: "stopper", Pattern: "urgent", StopProcessing: true},
+			{ID: "generic", Pattern: "promo"},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"generic"}, m.MatchingRules("promo"))
+	})
+}
+
+func TestMatchMessageConditions(t *testing.T) {
+	onlyAdmins := func(msg *matcher.Message) bool { return msg.Sender.Admin() }
+
+	m, err := matcher.New([]matcher.MatchRule{
+		{ID: "admins", Pattern: "release", Conditions: []matcher.Condition{onlyAdmins}},
+		{ID: "anyone", Pattern: "release"},
+	})
+	require.NoError(t, err)
+
+	t.Run("should skip rules whose conditions fail", func(t *testing.T) {
+		msg := &matcher.Message{Text: "new release", Sender: matcher.Sender{ID: 1}}
+
+		require.Equal(t, []string{"anyone"}, m.MatchMessage(msg))
+	})
+
+	t.Run("should match rules whose conditions hold", func(t *testing.T) {
+		msg := &matcher.Message{
+			Text:   "new release",
+			Sender: matcher.Sender{ID: 1, IsAdmin: func() bool { return true }},
+		}
+
+		require.Equal(t, []string{"admins", "anyone"}, m.MatchMessage(msg))
+	})
+
+	t.Run("should not evaluate conditions when the text does not match", func(t *testing.T) {
+		called := false
+		msg := &matcher.Message{
+			Text:   "unrelated",
+			Sender: matcher.Sender{IsAdmin: func() bool { called = true; return true }},
+		}
+
+		require.Empty(t, m.MatchMessage(msg))
+		require.False(t, called)
+	})
+}
+
+func TestExplain(t *testing.T) {
+	m, err := matcher.New([]matcher.MatchRule{
+		{ID: "pattern", Pattern: "promo[a-z]*"},
+		{ID: "keywords", Keywords: []string{"Hoje"}},
+		{ID: "stopper", Keywords: []string{"promoção"}, StopProcessing: true},
+		{ID: "after", Pattern: "hoje"},
+	})
+	require.NoError(t, err)
+
+	explanation := m.Explain(&matcher.Message{Text: "Promoção válida HOJE! promo"})
+
+	require.Equal(t, "promocao valida hoje promo", explanation.Normalized)
+	require.Equal(t, []string{"pattern", "keywords", "stopper"}, explanation.Matched)
+	require.Len(t, explanation.Rules, 4)
+	require.Equal(t, []matcher.Span{{Start: 0, End: 8}, {Start: 21, End: 26}}, explanation.Rules[0].Spans)
+	require.Equal(t, []matcher.Span{{Start: 16, End: 20}}, explanation.Rules[1].Spans)
+	require.Equal(t, "after", explanation.Rules[3].ID)
+}
+
+func TestMatchMessageContextTracing(t *testing.T) {
+	spans := tracingtest.Record(t)
+
+	m, err := matcher.New([]matcher.MatchRule{
+		{ID: "deals", Keywords: []string{"promo"}},
+		{ID: "codes", Pattern: `[0-9]{6}`},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"deals"}, m.MatchMessageContext(context.Background(), &matcher.Message{Text: "promo today"}))
+
+	recorded := spans.GetSpans()
+	require.Len(t, recorded, 1)
+	require.Equal(t, "matcher.match", recorded[0].Name)
+	require.Contains(t, recorded[0].Attributes, attribute.Int("matcher.rules", 2))
+	require.Contains(t, recorded[0].Attributes, attribute.StringSlice("matcher.matched", []string{"deals"}))
+}
@@ -0,0 +1,43 @@
+package matches
+
+import (
+	"net/http"
+
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+)
+
+type ListResponse struct {
+	Matches []Event `json:"matches"`
+	Total   int64   `json:"total"`
+	Limit   int     `json:"limit"`
+	Offset  int     `json:"offset"`
+}
+
+type Handler struct {
+	repo *Repository
+}
+
+func NewHandler(repo *Repository) *Handler {
+	return &Handler{
+		repo: repo,
+	}
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) (*rules.DataResponse, *rules.Error) {
+	query, err := ParseQuery(r.URL.Query())
+	if err != nil {
+		return nil, rules.NewError(http.StatusBadRequest, "INVALID_QUERY", err.Error())
+	}
+
+	events, total, err := h.repo.Find(query)
+	if err != nil {
+		return nil, rules.NewError(http.StatusInternalServerError, "MATCHES_UNAVAILABLE", err.Error())
+	}
+
+	return &rules.DataResponse{Data: ListResponse{
+		Matches: events,
+		Total:   total,
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}}, nil
+}
@@ -0,0 +1,104 @@
+package matches
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+type Query struct {
+	RuleID string
+	Chat   int64
+	Since  time.Time
+	Until  time.Time
+	Search string
+	Limit  int
+	Offset int
+}
+
+func ParseQuery(values url.Values) (Query, error) {
+	query := Query{
+		RuleID: values.Get("rule_id"),
+		Search: values.Get("q"),
+		Limit:  defaultLimit,
+	}
+
+	var err error
+	if chat := values.Get("chat"); chat != "" {
+		if query.Chat, err = strconv.ParseInt(chat, 10, 64); err != nil {
+			return query, fmt.Errorf("chat must be a number")
+		}
+	}
+
+	if query.Since, err = parseTime(values, "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseTime(values, "until"); err != nil {
+		return query, err
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return query, fmt.Errorf("since must be before until")
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > maxLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	if offset := values.Get("offset"); offset != "" {
+		if query.Offset, err = strconv.Atoi(offset); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("offset must not be negative")
+		}
+	}
+
+	return query, nil
+}
+
+func parseTime(values url.Values, key string) (time.Time, error) {
+	value := values.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", key)
+	}
+	return t, nil
+}
+
+func (q Query) filter() bson.M {
+	filter := bson.M{}
+
+	if q.RuleID != "" {
+		filter["rule_ids"] = q.RuleID
+	}
+	if q.Chat != 0 {
+		filter["chat"] = q.Chat
+	}
+
+	matchedAt := bson.M{}
+	if !q.Since.IsZero() {
+		matchedAt["$gte"] = q.Since
+	}
+	if !q.Until.IsZero() {
+		matchedAt["$lt"] = q.Until
+	}
+	if len(matchedAt) > 0 {
+		filter["matched_at"] = matchedAt
+	}
+
+	if q.Search != "" {
+		filter["$text"] = bson.M{"$search": q.Search}
+	}
+
+	return filter
+}
@@ -0,0 +1,58 @@
+package matches
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseQuery(t *testing.T) {
+	t.Run("should apply defaults", func(t *testing.T) {
+		query, err := ParseQuery(url.Values{})
+		require.NoError(t, err)
+		require.Equal(t, Query{Limit: defaultLimit}, query)
+		require.Equal(t, bson.M{}, query.filter())
+	})
+
+	t.Run("should build a filter from every parameter", func(t *testing.T) {
+		query, err := ParseQuery(url.Values{
+			"rule_id": {"deals"},
+			"chat":    {"1001"},
+			"since":   {"2025-01-01T00:00:00Z"},
+			"until":   {"2025-02-01T00:00:00Z"},
+			"q":       {"promo"},
+			"limit":   {"20"},
+			"offset":  {"40"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 20, query.Limit)
+		require.Equal(t, 40, query.Offset)
+
+		require.Equal(t, bson.M{
+			"rule_ids": "deals",
+			"chat":     int64(1001),
+			"matched_at": bson.M{
+				"$gte": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				"$lt":  time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+			"$text": bson.M{"$search": "promo"},
+		}, query.filter())
+	})
+
+	t.Run("should reject invalid parameters", func(t *testing.T) {
+		for _, values := range []url.Values{
+			{"chat": {"abc"}},
+			{"since": {"yesterday"}},
+			{"since": {"2025-02-01T00:00:00Z"}, "until": {"2025-01-01T00:00:00Z"}},
+			{"limit": {"0"}},
+			{"limit": {"1000"}},
+			{"offset": {"-1"}},
+		} {
+			_, err := ParseQuery(values)
+			require.Error(t, err, values)
+		}
+	})
+}
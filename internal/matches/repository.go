@@ -0,0 +1,162 @@
+package matches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/delivery"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ttlIndexName  = "matched_at_ttl"
+	StatusPending = "pending"
+)
+
+var pendingStatuses = []string{
+	StatusPending,
+	string(delivery.StatusQueued),
+	string(delivery.StatusCollapsed),
+	string(delivery.StatusDigest),
+	string(delivery.StatusDeferred),
+}
+
+type Event struct {
+	ID        string    `json:"id" bson:"_id"`
+	Account   string    `json:"account" bson:"account"`
+	Chat      int64     `json:"chat" bson:"chat"`
+	MessageID int       `json:"message_id" bson:"message_id"`
+	Sender    Sender    `json:"sender" bson:"sender"`
+	Text      string    `json:"text" bson:"text"`
+	RuleIDs   []string  `json:"rule_ids" bson:"rule_ids"`
+	RuleID    string    `json:"rule_id,omitempty" bson:"rule_id,omitempty"`
+	Status    string    `json:"status" bson:"status"`
+	Error     string    `json:"error,omitempty" bson:"error,omitempty"`
+	SentAt    time.Time `json:"sent_at" bson:"sent_at"`
+	MatchedAt time.Time `json:"matched_at" bson:"matched_at"`
+}
+
+type Sender struct {
+	ID       int64  `json:"id,omitempty" bson:"id,omitempty"`
+	Username string `json:"username,omitempty" bson:"username,omitempty"`
+}
+
+type Repository struct {
+	collection *mongo.Collection
+}
+
+func NewRepository(client *mongo.Client, database string, retention time.Duration) (*Repository, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := client.Database(database)
+	coll := db.Collection("matches")
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "rule_ids", Value: 1}, {Key: "matched_at", Value: -1}}},
+		{Keys: bson.D{{Key: "chat", Value: 1}, {Key: "matched_at", Value: -1}}},
+		{Keys: bson.D{{Key: "text", Value: "text"}}},
+	}
+	if _, err := coll.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	if err := ensureTTL(ctx, db, coll, retention); err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		collection: coll,
+	}, nil
+}
+
+func ensureTTL(ctx context.Context, db *mongo.Database, coll *mongo.Collection, retention time.Duration) error {
+	seconds := int32(retention.Seconds())
+
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "matched_at", Value: 1}},
+		Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(seconds),
+	})
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Name == "IndexOptionsConflict" {
+		err = db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: ttlIndexName},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set match retention: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) Record(event Event) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event.ID = uuid.New().String()
+	if event.MatchedAt.IsZero() {
+		event.MatchedAt = time.Now()
+	}
+
+	if _, err := r.collection.InsertOne(ctx, event); err != nil {
+		return "", fmt.Errorf("failed to record match: %w", err)
+	}
+	return event.ID, nil
+}
+
+func (r *Repository) UpdateStatus(id string, status delivery.Status, deliveryErr error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"status": string(status)}, "$unset": bson.M{"error": ""}}
+	if deliveryErr != nil {
+		update = bson.M{"$set": bson.M{"status": string(status), "error": deliveryErr.Error()}}
+	}
+
+	filter := bson.M{"_id": id, "status": bson.M{"$in": pendingStatuses}}
+	if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("failed to update match status: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) Find(query Query) ([]Event, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := query.filter()
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count matches: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "matched_at", Value: -1}}).
+		SetSkip(int64(query.Offset)).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find matches: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []Event{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode matches: %w", err)
+	}
+
+	return events, total, nil
+}
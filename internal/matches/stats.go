@@ -0,0 +1,140 @@
+package matches
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/delivery"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *Repository) RuleStats(ruleID string, since time.Time) (map[string]*rules.RuleStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"matched_at": bson.M{"$gte": since}}
+	if ruleID != "" {
+		filter["rule_ids"] = ruleID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$unwind", Value: "$rule_ids"}},
+	}
+	if ruleID != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"rule_ids": ruleID}}})
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, append(pipeline,
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":             bson.M{"rule_id": "$rule_ids", "status": decidedStatus("$rule_ids")},
+			"count":           bson.M{"$sum": 1},
+			"last_matched_at": bson.M{"$max": "$matched_at"},
+		}}},
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate rule statistics: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			RuleID string `bson:"rule_id"`
+			Status string `bson:"status"`
+		} `bson:"_id"`
+		Count         int64     `bson:"count"`
+		LastMatchedAt time.Time `bson:"last_matched_at"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode rule statistics: %w", err)
+	}
+
+	stats := map[string]*rules.RuleStats{}
+	for _, row := range rows {
+		st, ok := stats[row.ID.RuleID]
+		if !ok {
+			st = &rules.RuleStats{}
+			stats[row.ID.RuleID] = st
+		}
+
+		addStatus(st, row.ID.Status, row.Count)
+		if st.LastMatchedAt == nil || row.LastMatchedAt.After(*st.LastMatchedAt) {
+			last := row.LastMatchedAt
+			st.LastMatchedAt = &last
+		}
+	}
+
+	return stats, nil
+}
+
+func (r *Repository) RuleSeries(ruleID string, since time.Time, bucket rules.StatsBucket) ([]rules.StatsPoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"rule_ids": ruleID, "matched_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"start":  bson.M{"$dateTrunc": bson.M{"date": "$matched_at", "unit": string(bucket)}},
+				"status": decidedStatus(ruleID),
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.start", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate rule series: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			Start  time.Time `bson:"start"`
+			Status string    `bson:"status"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode rule series: %w", err)
+	}
+
+	series := []rules.StatsPoint{}
+	for _, row := range rows {
+		if len(series) == 0 || !series[len(series)-1].Start.Equal(row.ID.Start) {
+			series = append(series, rules.StatsPoint{Start: row.ID.Start})
+		}
+
+		point := &series[len(series)-1]
+		var st rules.RuleStats
+		addStatus(&st, row.ID.Status, row.Count)
+		point.Matches += st.Matches
+		point.Deliveries += st.Deliveries
+		point.Failures += st.Failures
+	}
+
+	return series, nil
+}
+
+func decidedStatus(ruleID any) bson.M {
+	return bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$rule_id", ruleID}}, "$status", ""}}
+}
+
+func addStatus(st *rules.RuleStats, status string, count int64) {
+	st.Matches += count
+
+	switch delivery.Status(status) {
+	case delivery.StatusSent, delivery.StatusQueued, delivery.StatusDigest, delivery.StatusDeferred:
+		st.Deliveries += count
+	case delivery.StatusFailed:
+		st.Failures += count
+	case delivery.StatusSuppressed:
+		st.Suppressed += count
+	case delivery.StatusCollapsed:
+		st.Collapsed += count
+	case delivery.StatusDropped:
+		st.Dropped += count
+	}
+}
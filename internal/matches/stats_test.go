@@ -0,0 +1,23 @@
+package matches
+
+import (
+	"testing"
+
+	"github.com/gabrielmelo/tg-forward/internal/delivery"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddStatus(t *testing.T) {
+	var st rules.RuleStats
+
+	addStatus(&st, string(delivery.StatusSent), 5)
+	addStatus(&st, string(delivery.StatusDigest), 2)
+	addStatus(&st, string(delivery.StatusFailed), 1)
+	addStatus(&st, string(delivery.StatusCollapsed), 3)
+	addStatus(&st, string(delivery.StatusSuppressed), 1)
+	addStatus(&st, string(delivery.StatusDropped), 2)
+	addStatus(&st, "", 4)
+
+	require.Equal(t, rules.RuleStats{Matches: 18, Deliveries: 7, Failures: 1, Suppressed: 1, Collapsed: 3, Dropped: 2}, st)
+}
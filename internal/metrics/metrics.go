@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tgforward"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	UpdateDifferences = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "update_differences_total",
+		Help:      "Difference requests made to recover missed updates, by scope (common or channel).",
+	}, []string{"scope"})
+
+	UpdatesRecovered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_recovered_total",
+		Help:      "Missed updates recovered through difference requests, by scope (common or channel).",
+	}, []string{"scope"})
+
+	UpdateChannelTooLong = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "update_channel_too_long_total",
+		Help:      "Channels whose gap was too long to recover.",
+	})
+
+	MessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Messages received by the user clients, by chat type (channel or supergroup).",
+	}, []string{"chat_type"})
+
+	MatchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "match_duration_seconds",
+		Help:      "Time spent matching a message against the rules.",
+		Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+	})
+
+	RuleMatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_matches_total",
+		Help:      "Received messages matched, by rule ID.",
+	}, []string{"rule"})
+
+	BotSendDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bot_send_duration_seconds",
+		Help:      "Time taken by the Bot API to send a message, by bot.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"bot"})
+
+	BotSendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bot_send_errors_total",
+		Help:      "Failed Bot API sends, by bot and Telegram error code (network when there is none).",
+	}, []string{"bot", "code"})
+
+	OutboxDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_depth",
+		Help:      "Messages waiting in the rate-limited delivery queues, including collapsed ones.",
+	})
+
+	UserClientConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "user_client_connected",
+		Help:      "Whether the MTProto connection of a user account is up (1) or down (0).",
+	}, []string{"account"})
+
+	Leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this instance holds the leader lease (1) or is a follower (0).",
+	})
+
+	LeaderTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_transitions_total",
+		Help:      "Leadership changes of this instance, by direction (acquired or lost).",
+	}, []string{"direction"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP API request durations, by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		UpdateDifferences,
+		UpdatesRecovered,
+		UpdateChannelTooLong,
+		MessagesReceived,
+		MatchDuration,
+		RuleMatches,
+		BotSendDuration,
+		BotSendErrors,
+		OutboxDepth,
+		UserClientConnected,
+		Leader,
+		LeaderTransitions,
+		HTTPRequestDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
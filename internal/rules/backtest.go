@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+)
+
+const (
+	defaultBacktestSamples = 5
+	defaultBacktestLimit   = 1000
+	maxBacktestLimit       = 10000
+	backtestTimeout        = 2 * time.Minute
+)
+
+type HistoryMessage struct {
+	Chat    string
+	ID      int
+	Date    time.Time
+	Message *matcher.Message
+}
+
+type HistorySource interface {
+	History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(HistoryMessage) error) error
+}
+
+type LinkResolver interface {
+	ResolveAll(ctx context.Context, urls []string) []string
+}
+
+func (s *Service) SetHistorySource(history HistorySource) {
+	s.history = history
+}
+
+func (s *Service) SetLinkResolver(resolver LinkResolver) {
+	s.links = resolver
+}
+
+func (s *Service) Backtest(ctx context.Context, req BacktestRequest) (*BacktestResponse, error) {
+	if s.history == nil {
+		return nil, ErrBacktestUnavailable
+	}
+
+	if len(req.Chats) == 0 {
+		return nil, fmt.Errorf("at least one chat is required")
+	}
+
+	until := req.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	if req.Since.IsZero() || !req.Since.Before(until) {
+		return nil, fmt.Errorf("since must be before until")
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultBacktestLimit
+	}
+	if limit > maxBacktestLimit {
+		return nil, fmt.Errorf("limit must not exceed %d messages per chat", maxBacktestLimit)
+	}
+
+	samples := req.Samples
+	if samples <= 0 {
+		samples = defaultBacktestSamples
+	}
+
+	candidates, err := s.backtestRules(req.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	matchRules := make([]matcher.MatchRule, 0, len(candidates))
+	reports := make(map[string]*RuleBacktest, len(candidates))
+	response := &BacktestResponse{Since: req.Since, Until: until, Rules: make([]*RuleBacktest, 0, len(candidates))}
+	for _, rule := range candidates {
+		matchRules = append(matchRules, rule.matchRule())
+		report := &RuleBacktest{RuleID: rule.ID, RuleName: rule.Name, Samples: []BacktestSample{}}
+		reports[rule.ID] = report
+		response.Rules = append(response.Rules, report)
+	}
+
+	m, err := matcher.New(matchRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create matcher: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, backtestTimeout)
+	defer cancel()
+
+	for _, chat := range req.Chats {
+		err := s.history.History(ctx, chat, req.Since, until, limit, func(msg HistoryMessage) error {
+			if s.links != nil {
+				msg.Message.URLs = s.links.ResolveAll(ctx, msg.Message.URLs)
+			}
+
+			response.Scanned++
+			ids := m.MatchMessage(msg.Message)
+			if len(ids) > 0 {
+				response.Matched++
+			}
+
+			for _, id := range ids {
+				report := reports[id]
+				report.Hits++
+				if len(report.Samples) < samples {
+					report.Samples = append(report.Samples, BacktestSample{
+						Chat:      msg.Chat,
+						MessageID: msg.ID,
+						Date:      msg.Date,
+						Text:      msg.Message.Text,
+					})
+				}
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return nil, fmt.Errorf("%w: stopped after %s", ErrBacktestTimeout, backtestTimeout)
+		case err != nil:
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func (s *Service) backtestRules(requested []AddRuleRequest) ([]Rule, error) {
+	if len(requested) == 0 {
+		existing, err := s.repo.GetRules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rules: %w", err)
+		}
+
+		now := time.Now()
+		active := make([]Rule, 0, len(existing))
+		for _, rule := range existing {
+			if rule.Active(now) {
+				active = append(active, rule)
+			}
+		}
+		return active, nil
+	}
+
+	candidates := make([]Rule, 0, len(requested))
+	for i, r := range requested {
+		rule := r.toRule()
+		rule.ID = fmt.Sprintf("%s-%d", CandidateRuleID, i+1)
+		if rule.Name == "" {
+			rule.Name = rule.ID
+		}
+		if err := s.validateRule(rule); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, rule)
+	}
+	return candidates, nil
+}
@@ -0,0 +1,235 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabrielmelo/tg-forward/internal/language"
+	"github.com/gabrielmelo/tg-forward/internal/links"
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+)
+
+var MediaTypes = []string{
+	"none", "photo", "video", "video_note", "animation", "audio", "voice",
+	"document", "sticker", "poll", "location", "contact", "webpage", "other",
+}
+
+type SenderCondition struct {
+	UserIDs                []int64  `json:"user_ids,omitempty" bson:"user_ids,omitempty"`
+	Usernames              []string `json:"usernames,omitempty" bson:"usernames,omitempty"`
+	OnlyAdmins             bool     `json:"only_admins,omitempty" bson:"only_admins,omitempty"`
+	ExcludeBots            bool     `json:"exclude_bots,omitempty" bson:"exclude_bots,omitempty"`
+	ForwardedFromIDs       []int64  `json:"forwarded_from_ids,omitempty" bson:"forwarded_from_ids,omitempty"`
+	ForwardedFromUsernames []string `json:"forwarded_from_usernames,omitempty" bson:"forwarded_from_usernames,omitempty"`
+	OnlyPremium            bool     `json:"only_premium,omitempty" bson:"only_premium,omitempty"`
+	OnlyVerified           bool     `json:"only_verified,omitempty" bson:"only_verified,omitempty"`
+}
+
+type MessageCondition struct {
+	Media       []string `json:"media,omitempty" bson:"media,omitempty"`
+	HasLink     bool     `json:"has_link,omitempty" bson:"has_link,omitempty"`
+	LinkDomains []string `json:"link_domains,omitempty" bson:"link_domains,omitempty"`
+	LinkPattern string   `json:"link_pattern,omitempty" bson:"link_pattern,omitempty"`
+	MinLength   int      `json:"min_length,omitempty" bson:"min_length,omitempty"`
+	MaxLength   int      `json:"max_length,omitempty" bson:"max_length,omitempty"`
+	Languages   []string `json:"languages,omitempty" bson:"languages,omitempty"`
+}
+
+func (r Rule) matchRule() matcher.MatchRule {
+	return matcher.MatchRule{
+		ID:             r.ID,
+		Pattern:        r.Pattern,
+		Keywords:       r.Keywords,
+		StopProcessing: r.StopProcessing,
+		Conditions:     r.conditions(),
+	}
+}
+
+func (r Rule) conditions() []matcher.Condition {
+	var conditions []matcher.Condition
+
+	if len(r.Accounts) > 0 {
+		accounts := r.Accounts
+		conditions = append(conditions, func(msg *matcher.Message) bool {
+			return slices.Contains(accounts, msg.Account)
+		})
+	}
+
+	if len(r.MutedSources) > 0 {
+		muted := r.MutedSources
+		conditions = append(conditions, func(msg *matcher.Message) bool {
+			return !slices.Contains(muted, msg.Chat)
+		})
+	}
+
+	if r.Sender != nil {
+		conditions = append(conditions, r.Sender.matches)
+	}
+
+	if r.Message != nil {
+		conditions = append(conditions, r.Message.compile())
+	}
+
+	return conditions
+}
+
+func (c *SenderCondition) matches(msg *matcher.Message) bool {
+	sender := msg.Sender
+
+	if len(c.UserIDs) > 0 || len(c.Usernames) > 0 {
+		if !slices.Contains(c.UserIDs, sender.ID) && !containsUsername(c.Usernames, sender.Username) {
+			return false
+		}
+	}
+
+	if len(c.ForwardedFromIDs) > 0 || len(c.ForwardedFromUsernames) > 0 {
+		if !slices.Contains(c.ForwardedFromIDs, sender.ForwardedFromID) &&
+			!containsUsername(c.ForwardedFromUsernames, sender.ForwardedFromUsername) {
+			return false
+		}
+	}
+
+	if c.ExcludeBots && sender.Bot {
+		return false
+	}
+	if c.OnlyPremium && !sender.Premium {
+		return false
+	}
+	if c.OnlyVerified && !sender.Verified {
+		return false
+	}
+
+	if c.OnlyAdmins && !sender.Admin() {
+		return false
+	}
+
+	return true
+}
+
+func containsUsername(usernames []string, username string) bool {
+	if username == "" {
+		return false
+	}
+	for _, u := range usernames {
+		if strings.EqualFold(strings.TrimPrefix(u, "@"), username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *MessageCondition) compile() matcher.Condition {
+	domains := make([]*regexp.Regexp, 0, len(c.LinkDomains))
+	for _, domain := range c.LinkDomains {
+		domains = append(domains, domainPattern(domain))
+	}
+
+	var linkPattern *regexp.Regexp
+	if c.LinkPattern != "" {
+		linkPattern, _ = regexp.Compile(c.LinkPattern)
+	}
+
+	return func(msg *matcher.Message) bool {
+		if len(c.Media) > 0 {
+			media := msg.Media
+			if media == "" {
+				media = "none"
+			}
+			if !slices.Contains(c.Media, media) {
+				return false
+			}
+		}
+
+		if c.HasLink && len(msg.URLs) == 0 {
+			return false
+		}
+
+		if len(domains) > 0 && !linksToAny(msg.URLs, domains) {
+			return false
+		}
+
+		if c.LinkPattern != "" && (linkPattern == nil || !slices.ContainsFunc(msg.URLs, linkPattern.MatchString)) {
+			return false
+		}
+
+		length := utf8.RuneCountInString(strings.TrimSpace(msg.Text))
+		if c.MinLength > 0 && length < c.MinLength {
+			return false
+		}
+		if c.MaxLength > 0 && length > c.MaxLength {
+			return false
+		}
+
+		if len(c.Languages) > 0 && !slices.Contains(c.Languages, language.Detect(msg.Text)) {
+			return false
+		}
+
+		return true
+	}
+}
+
+func domainPattern(domain string) *regexp.Regexp {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+	parts := strings.Split(domain, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile(`^(.+\.)?` + strings.Join(parts, `.+`) + `$`)
+}
+
+func linksToAny(urls []string, domains []*regexp.Regexp) bool {
+	for _, link := range urls {
+		host := links.Host(link)
+		if host == "" {
+			continue
+		}
+		for _, domain := range domains {
+			if domain.MatchString(host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateMessageCondition(condition *MessageCondition) error {
+	if condition == nil {
+		return nil
+	}
+
+	for _, media := range condition.Media {
+		if !slices.Contains(MediaTypes, media) {
+			return fmt.Errorf("invalid media type '%s': must be one of %s", media, strings.Join(MediaTypes, ", "))
+		}
+	}
+
+	if condition.MinLength < 0 || condition.MaxLength < 0 {
+		return fmt.Errorf("message length limits must not be negative")
+	}
+	if condition.MaxLength > 0 && condition.MinLength > condition.MaxLength {
+		return fmt.Errorf("min_length must not be greater than max_length")
+	}
+
+	for _, lang := range condition.Languages {
+		if !slices.Contains(language.Supported(), lang) {
+			return fmt.Errorf("unsupported language '%s': must be one of %s", lang, strings.Join(language.Supported(), ", "))
+		}
+	}
+
+	for _, domain := range condition.LinkDomains {
+		if strings.TrimSpace(domain) == "" {
+			return fmt.Errorf("link domains must not be empty")
+		}
+	}
+
+	if condition.LinkPattern != "" {
+		if _, err := regexp.Compile(condition.LinkPattern); err != nil {
+			return fmt.Errorf("invalid link pattern '%s': %w", condition.LinkPattern, err)
+		}
+	}
+
+	return nil
+}
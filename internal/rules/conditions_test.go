@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageCondition(t *testing.T) {
+	t.Run("should match link domains with wildcards and subdomains", func(t *testing.T) {
+		condition := (&MessageCondition{LinkDomains: []string{"amazon.*", "example.com"}}).compile()
+
+		require.True(t, condition(&matcher.Message{URLs: []string{"https://www.amazon.com.br/dp/123"}}))
+		require.True(t, condition(&matcher.Message{URLs: []string{"shop.example.com/item"}}))
+		require.False(t, condition(&matcher.Message{URLs: []string{"https://notexample.com"}}))
+		require.False(t, condition(&matcher.Message{}))
+	})
+
+	t.Run("should filter on media type", func(t *testing.T) {
+		condition := (&MessageCondition{Media: []string{"photo", "none"}}).compile()
+
+		require.True(t, condition(&matcher.Message{Media: "photo"}))
+		require.True(t, condition(&matcher.Message{}))
+		require.False(t, condition(&matcher.Message{Media: "video"}))
+	})
+
+	t.Run("should filter on text length", func(t *testing.T) {
+		condition := (&MessageCondition{MinLength: 5, MaxLength: 10}).compile()
+
+		require.False(t, condition(&matcher.Message{Text: "oi"}))
+		require.True(t, condition(&matcher.Message{Text: "promoção"}))
+		require.False(t, condition(&matcher.Message{Text: "a very long message"}))
+	})
+
+	t.Run("should filter on detected language", func(t *testing.T) {
+		condition := (&MessageCondition{Languages: []string{"pt"}}).compile()
+
+		require.True(t, condition(&matcher.Message{Text: "A promoção de hoje está disponível para todos os clientes da loja"}))
+		require.False(t, condition(&matcher.Message{Text: "Today's deal is available for all of the customers in the store"}))
+	})
+}
+
+func TestSenderCondition(t *testing.T) {
+	condition := &SenderCondition{Usernames: []string{"@Alice"}, ExcludeBots: true}
+
+	require.True(t, condition.matches(&matcher.Message{Sender: matcher.Sender{Username: "alice"}}))
+	require.False(t, condition.matches(&matcher.Message{Sender: matcher.Sender{Username: "alice", Bot: true}}))
+	require.False(t, condition.matches(&matcher.Message{Sender: matcher.Sender{Username: "bob"}}))
+}
+
+func TestAccountCondition(t *testing.T) {
+	rule := Rule{ID: "work", Pattern: "deploy", Accounts: []string{"work"}}
+
+	m, err := matcher.New([]matcher.MatchRule{rule.matchRule()})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"work"}, m.MatchMessage(&matcher.Message{Account: "work", Text: "deploy now"}))
+	require.Empty(t, m.MatchMessage(&matcher.Message{Account: "personal", Text: "deploy now"}))
+}
+
+func TestMutedSources(t *testing.T) {
+	rule := Rule{ID: "deals", Keywords: []string{"promo"}, MutedSources: []int64{1001}}
+
+	m, err := matcher.New([]matcher.MatchRule{rule.matchRule()})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"deals"}, m.MatchMessage(&matcher.Message{Chat: 2002, Text: "promo today"}))
+	require.Empty(t, m.MatchMessage(&matcher.Message{Chat: 1001, Text: "promo today"}))
+}
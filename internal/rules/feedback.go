@@ -0,0 +1,130 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FeedbackKind string
+
+const (
+	FeedbackMuted       FeedbackKind = "muted"
+	FeedbackMutedSource FeedbackKind = "muted_source"
+	FeedbackNotRelevant FeedbackKind = "not_relevant"
+)
+
+type Feedback struct {
+	ID        string       `json:"id" bson:"_id"`
+	RuleID    string       `json:"rule_id" bson:"rule_id"`
+	Kind      FeedbackKind `json:"kind" bson:"kind"`
+	Source    int64        `json:"source,omitempty" bson:"source,omitempty"`
+	UserID    int64        `json:"user_id" bson:"user_id"`
+	CreatedAt time.Time    `json:"created_at" bson:"created_at"`
+}
+
+func (r *Repository) AddFeedback(feedback Feedback) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	feedback.ID = generateID()
+	if _, err := r.feedback.InsertOne(ctx, feedback); err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) AddMutedSource(id string, source int64) (*Rule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var updatedRule Rule
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$addToSet": bson.M{"muted_sources": source}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedRule)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("rule not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to update rule: %w", err)
+	}
+
+	return &updatedRule, nil
+}
+
+func (r *Repository) FeedbackCounts(ruleID string, since time.Time) (map[string]map[FeedbackKind]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"created_at": bson.M{"$gte": since}}
+	if ruleID != "" {
+		filter["rule_id"] = ruleID
+	}
+
+	cursor, err := r.feedback.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"rule_id": "$rule_id", "kind": "$kind"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count feedback: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			RuleID string       `bson:"rule_id"`
+			Kind   FeedbackKind `bson:"kind"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode feedback counts: %w", err)
+	}
+
+	counts := map[string]map[FeedbackKind]int64{}
+	for _, row := range rows {
+		if counts[row.ID.RuleID] == nil {
+			counts[row.ID.RuleID] = map[FeedbackKind]int64{}
+		}
+		counts[row.ID.RuleID][row.ID.Kind] = row.Count
+	}
+
+	return counts, nil
+}
+
+func (s *Service) RecordFeedback(ruleID string, kind FeedbackKind, source, userID int64) error {
+	return s.repo.AddFeedback(Feedback{
+		RuleID:    ruleID,
+		Kind:      kind,
+		Source:    source,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (s *Service) MuteSource(id string, source int64) (*Rule, error) {
+	if source == 0 {
+		return nil, fmt.Errorf("message source is unknown")
+	}
+
+	rule, err := s.repo.AddMutedSource(id, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
@@ -1,8 +1,10 @@
 package rules
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 type Handler struct {
@@ -17,7 +19,43 @@ func NewHandler(svc *Service) *Handler {
 
 func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) (*DataResponse, *Error) {
 	rules := h.service.GetRules()
-	return &DataResponse{Data: RulesResponse{Rules: rules}}, nil
+
+	stats, err := h.service.Stats(time.Now().Add(-defaultStatsRange))
+	if err != nil && !errors.Is(err, ErrStatsUnavailable) {
+		slog.ErrorContext(r.Context(), "Failed to load rule statistics", "error", err)
+	}
+
+	return &DataResponse{Data: RulesResponse{Rules: rules, Stats: stats}}, nil
+}
+
+func (h *Handler) GetRuleStats(w http.ResponseWriter, r *http.Request, id string) (*DataResponse, *Error) {
+	if _, ok := h.service.GetRule(id); !ok {
+		return nil, NewError(http.StatusNotFound, "RULE_NOT_FOUND", "rule not found: "+id)
+	}
+
+	since := time.Now().Add(-defaultStatsRange)
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, NewError(http.StatusBadRequest, "INVALID_QUERY", "since must be an RFC 3339 timestamp")
+		}
+		since = parsed
+	}
+
+	bucket := StatsBucket(r.URL.Query().Get("bucket"))
+	if bucket == "" {
+		bucket = BucketDay
+	}
+
+	result, err := h.service.RuleStats(id, since, bucket)
+	if errors.Is(err, ErrStatsUnavailable) {
+		return nil, NewError(http.StatusServiceUnavailable, "STATS_UNAVAILABLE", err.Error())
+	}
+	if err != nil {
+		return nil, NewError(http.StatusBadRequest, "INVALID_QUERY", err.Error())
+	}
+
+	return &DataResponse{Data: result}, nil
 }
 
 func (h *Handler) UpdateRules(w http.ResponseWriter, r *http.Request, body *UpdateRulesRequest) (*DataResponse, *Error) {
@@ -26,36 +64,112 @@ func (h *Handler) UpdateRules(w http.ResponseWriter, r *http.Request, body *Upda
 		return nil, NewError(http.StatusBadRequest, "INVALID_RULES", err.Error())
 	}
 
-	log.Printf("Rules updated: %d rules", len(rules))
+	slog.InfoContext(r.Context(), "Rules updated", "count", len(rules))
 	return &DataResponse{Data: RulesResponse{Rules: rules}}, nil
 }
 
 func (h *Handler) AddRule(w http.ResponseWriter, r *http.Request, body *AddRuleRequest) (*DataResponse, *Error) {
-	rule, err := h.service.AddRule(body.Name, body.Pattern, body.Keywords)
+	rule, err := h.service.AddRule(body.toRule())
 	if err != nil {
 		return nil, NewError(http.StatusBadRequest, "INVALID_RULE", err.Error())
 	}
 
-	log.Printf("Rule added: %s (ID: %s)", rule.Name, rule.ID)
+	slog.InfoContext(r.Context(), "Rule added", "rule_id", rule.ID, "rule", rule.Name)
 	return &DataResponse{Data: RuleResponse{Rule: *rule}}, nil
 }
 
+func (h *Handler) ReorderRules(w http.ResponseWriter, r *http.Request, body *ReorderRulesRequest) (*DataResponse, *Error) {
+	rules, err := h.service.ReorderRules(body.IDs)
+	if err != nil {
+		return nil, NewError(http.StatusBadRequest, "INVALID_ORDER", err.Error())
+	}
+
+	slog.InfoContext(r.Context(), "Rules reordered", "count", len(rules))
+	return &DataResponse{Data: RulesResponse{Rules: rules}}, nil
+}
+
+func (h *Handler) TestRules(w http.ResponseWriter, r *http.Request, body *TestRulesRequest) (*DataResponse, *Error) {
+	var candidate *Rule
+	if body.Rule != nil {
+		rule := body.Rule.toRule()
+		rule.ID = body.RuleID
+		candidate = &rule
+	}
+
+	result, err := h.service.TestRules(body.Text, candidate)
+	if err != nil {
+		return nil, NewError(http.StatusBadRequest, "INVALID_RULE", err.Error())
+	}
+
+	return &DataResponse{Data: result}, nil
+}
+
+func (h *Handler) Backtest(w http.ResponseWriter, r *http.Request, body *BacktestRequest) (*DataResponse, *Error) {
+	result, err := h.service.Backtest(r.Context(), *body)
+	if errors.Is(err, ErrBacktestUnavailable) {
+		return nil, NewError(http.StatusServiceUnavailable, "BACKTEST_UNAVAILABLE", err.Error())
+	}
+	if errors.Is(err, ErrBacktestTimeout) {
+		return nil, NewError(http.StatusGatewayTimeout, "BACKTEST_TIMEOUT", err.Error())
+	}
+	if err != nil {
+		return nil, NewError(http.StatusBadRequest, "INVALID_BACKTEST", err.Error())
+	}
+
+	slog.InfoContext(r.Context(), "Backtest finished", "scanned", result.Scanned, "matched", result.Matched)
+	return &DataResponse{Data: result}, nil
+}
+
 func (h *Handler) RemoveRule(w http.ResponseWriter, r *http.Request, body *RemoveRuleRequest) (*DataResponse, *Error) {
 	err := h.service.RemoveRule(body.ID)
 	if err != nil {
 		return nil, NewError(http.StatusNotFound, "RULE_NOT_FOUND", err.Error())
 	}
 
-	log.Printf("Rule removed: %s", body.ID)
+	slog.InfoContext(r.Context(), "Rule removed", "rule_id", body.ID)
 	return &DataResponse{Data: map[string]string{"message": "rule deleted successfully"}}, nil
 }
 
 func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request, id string, body *UpdateRuleRequest) (*DataResponse, *Error) {
-	rule, err := h.service.UpdateRule(id, body.Name, body.Pattern, body.Keywords)
+	rule, err := h.service.UpdateRule(id, AddRuleRequest(*body).toRule())
 	if err != nil {
 		return nil, NewError(http.StatusBadRequest, "INVALID_RULE", err.Error())
 	}
 
-	log.Printf("Rule updated: %s (ID: %s)", rule.Name, rule.ID)
+	slog.InfoContext(r.Context(), "Rule updated", "rule_id", rule.ID, "rule", rule.Name)
+	return &DataResponse{Data: RuleResponse{Rule: *rule}}, nil
+}
+
+func (h *Handler) EnableRule(w http.ResponseWriter, r *http.Request, id string) (*DataResponse, *Error) {
+	rule, err := h.service.EnableRule(id)
+	if err != nil {
+		return nil, NewError(http.StatusNotFound, "RULE_NOT_FOUND", err.Error())
+	}
+
+	slog.InfoContext(r.Context(), "Rule enabled", "rule_id", rule.ID, "rule", rule.Name)
+	return &DataResponse{Data: RuleResponse{Rule: *rule}}, nil
+}
+
+func (h *Handler) DisableRule(w http.ResponseWriter, r *http.Request, id string) (*DataResponse, *Error) {
+	rule, err := h.service.DisableRule(id)
+	if err != nil {
+		return nil, NewError(http.StatusNotFound, "RULE_NOT_FOUND", err.Error())
+	}
+
+	slog.InfoContext(r.Context(), "Rule disabled", "rule_id", rule.ID, "rule", rule.Name)
+	return &DataResponse{Data: RuleResponse{Rule: *rule}}, nil
+}
+
+func (h *Handler) SnoozeRule(w http.ResponseWriter, r *http.Request, id string, body *SnoozeRuleRequest) (*DataResponse, *Error) {
+	if !body.Until.After(time.Now()) {
+		return nil, NewError(http.StatusBadRequest, "INVALID_SNOOZE", "snooze time must be in the future")
+	}
+
+	rule, err := h.service.SnoozeRule(id, body.Until)
+	if err != nil {
+		return nil, NewError(http.StatusNotFound, "RULE_NOT_FOUND", err.Error())
+	}
+
+	slog.InfoContext(r.Context(), "Rule snoozed", "rule_id", rule.ID, "rule", rule.Name, "until", body.Until)
 	return &DataResponse{Data: RuleResponse{Rule: *rule}}, nil
 }
@@ -2,6 +2,8 @@ package rules
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,17 +11,124 @@ import (
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Rule struct {
-	ID       string   `json:"id" bson:"_id"`
-	Name     string   `json:"name" bson:"name"`
-	Pattern  string   `json:"pattern,omitempty" bson:"pattern,omitempty"`
-	Keywords []string `json:"keywords,omitempty" bson:"keywords,omitempty"`
+	ID        string            `json:"id" bson:"_id"`
+	Name      string            `json:"name" bson:"name"`
+	Pattern   string            `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	Keywords  []string          `json:"keywords,omitempty" bson:"keywords,omitempty"`
+	RateLimit *RateLimit        `json:"rate_limit,omitempty" bson:"rate_limit,omitempty"`
+	Delivery  *DeliverySchedule `json:"delivery,omitempty" bson:"delivery,omitempty"`
+	Schedule  *ActiveSchedule   `json:"schedule,omitempty" bson:"schedule,omitempty"`
+
+	Priority       int        `json:"priority" bson:"priority"`
+	StopProcessing bool       `json:"stop_processing,omitempty" bson:"stop_processing,omitempty"`
+	Action         RuleAction `json:"action,omitempty" bson:"action,omitempty"`
+	Template       string     `json:"template,omitempty" bson:"template,omitempty"`
+	Target         string     `json:"target,omitempty" bson:"target,omitempty"`
+	MutedSources   []int64    `json:"muted_sources,omitempty" bson:"muted_sources,omitempty"`
+
+	Accounts []string          `json:"accounts,omitempty" bson:"accounts,omitempty"`
+	Sender   *SenderCondition  `json:"sender,omitempty" bson:"sender,omitempty"`
+	Message  *MessageCondition `json:"message,omitempty" bson:"message,omitempty"`
+
+	Enabled      bool       `json:"enabled" bson:"enabled"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty" bson:"snoozed_until,omitempty"`
 }
 
+type RuleAction string
+
+const (
+	ActionForward  RuleAction = "forward"
+	ActionSuppress RuleAction = "suppress"
+)
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type rule Rule
+	decoded := rule{Enabled: true}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = Rule(decoded)
+	return nil
+}
+
+func (r Rule) Active(now time.Time) bool {
+	if r.Enabled {
+		return true
+	}
+	return r.SnoozedUntil != nil && !r.SnoozedUntil.After(now)
+}
+
+type RateLimit struct {
+	PerMinute       int      `json:"per_minute,omitempty" bson:"per_minute,omitempty"`
+	Burst           int      `json:"burst,omitempty" bson:"burst,omitempty"`
+	CooldownSeconds int      `json:"cooldown_seconds,omitempty" bson:"cooldown_seconds,omitempty"`
+	Overflow        Overflow `json:"overflow,omitempty" bson:"overflow,omitempty"`
+}
+
+type Overflow string
+
+const (
+	OverflowDrop     Overflow = "drop"
+	OverflowQueue    Overflow = "queue"
+	OverflowCollapse Overflow = "collapse"
+)
+
+type DeliverySchedule struct {
+	Mode            DeliveryMode `json:"mode" bson:"mode"`
+	IntervalMinutes int          `json:"interval_minutes,omitempty" bson:"interval_minutes,omitempty"`
+	At              string       `json:"at,omitempty" bson:"at,omitempty"`
+	Timezone        string       `json:"timezone,omitempty" bson:"timezone,omitempty"`
+}
+
+type DeliveryMode string
+
+const (
+	DeliveryImmediate DeliveryMode = "immediate"
+	DeliveryInterval  DeliveryMode = "interval"
+	DeliveryDaily     DeliveryMode = "daily"
+)
+
+func (d *DeliverySchedule) IsDigest() bool {
+	return d != nil && (d.Mode == DeliveryInterval || d.Mode == DeliveryDaily)
+}
+
+type ActiveSchedule struct {
+	Timezone string           `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	Windows  []ScheduleWindow `json:"windows" bson:"windows"`
+	Outside  OutsidePolicy    `json:"outside,omitempty" bson:"outside,omitempty"`
+}
+
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty" bson:"days,omitempty"`
+	Start string   `json:"start" bson:"start"`
+	End   string   `json:"end" bson:"end"`
+}
+
+var Weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type OutsidePolicy string
+
+const (
+	OutsideDrop   OutsidePolicy = "drop"
+	OutsideDefer  OutsidePolicy = "defer"
+	OutsideSilent OutsidePolicy = "silent"
+)
+
 type Repository struct {
 	collection *mongo.Collection
+	feedback   *mongo.Collection
 }
 
 func NewRepository(client *mongo.Client, database, collection string) (*Repository, error) {
@@ -35,8 +144,26 @@ func NewRepository(client *mongo.Client, database, collection string) (*Reposito
 		return nil, fmt.Errorf("failed to create index: %w", err)
 	}
 
+	_, err := coll.UpdateMany(
+		ctx,
+		bson.M{"enabled": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"enabled": true}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate rules: %w", err)
+	}
+
+	feedback := client.Database(database).Collection(collection + "_feedback")
+	feedbackIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "rule_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}
+	if _, err := feedback.Indexes().CreateOne(ctx, feedbackIndex); err != nil {
+		return nil, fmt.Errorf("failed to create feedback index: %w", err)
+	}
+
 	return &Repository{
 		collection: coll,
+		feedback:   feedback,
 	}, nil
 }
 
@@ -44,7 +171,8 @@ func (r *Repository) GetRules() ([]Rule, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := r.collection.Find(ctx, bson.M{})
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find rules: %w", err)
 	}
@@ -82,39 +210,54 @@ func (r *Repository) SetRules(rules []Rule) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := r.collection.Drop(ctx); err != nil {
-		return fmt.Errorf("failed to drop collection: %w", err)
-	}
-
-	if len(rules) == 0 {
-		return nil
-	}
-
 	docs := make([]interface{}, len(rules))
 	for i, rule := range rules {
 		docs[i] = rule
 	}
 
-	_, err := r.collection.InsertMany(ctx, docs)
+	replace := func(ctx context.Context) error {
+		if _, err := r.collection.DeleteMany(ctx, bson.M{}); err != nil {
+			return fmt.Errorf("failed to delete rules: %w", err)
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+		if _, err := r.collection.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("failed to insert rules: %w", err)
+		}
+		return nil
+	}
+
+	session, err := r.collection.Database().Client().StartSession()
 	if err != nil {
-		return fmt.Errorf("failed to insert rules: %w", err)
+		return fmt.Errorf("failed to start session: %w", err)
 	}
+	defer session.EndSession(ctx)
 
-	return nil
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, replace(sc)
+	})
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(transactionsUnsupportedCode) {
+		return replace(ctx)
+	}
+	return err
 }
 
-func (r *Repository) AddRule(name, pattern string, keywords []string) (*Rule, error) {
+func (r *Repository) AddRule(rule Rule) (*Rule, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rule := Rule{
-		ID:       generateID(),
-		Name:     name,
-		Pattern:  pattern,
-		Keywords: keywords,
+	rule.ID = generateID()
+
+	priority, err := r.nextPriority(ctx)
+	if err != nil {
+		return nil, err
 	}
+	rule.Priority = priority
 
-	_, err := r.collection.InsertOne(ctx, rule)
+	_, err = r.collection.InsertOne(ctx, rule)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert rule: %w", err)
 	}
@@ -122,6 +265,38 @@ func (r *Repository) AddRule(name, pattern string, keywords []string) (*Rule, er
 	return &rule, nil
 }
 
+func (r *Repository) nextPriority(ctx context.Context) (int, error) {
+	var last Rule
+	opts := options.FindOne().SetSort(bson.D{{Key: "priority", Value: -1}})
+	err := r.collection.FindOne(ctx, bson.M{}, opts).Decode(&last)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to find last rule: %w", err)
+	}
+
+	return last.Priority + 1, nil
+}
+
+func (r *Repository) SetOrder(ids []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, len(ids))
+	for i, id := range ids {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$set": bson.M{"priority": i}})
+	}
+
+	if _, err := r.collection.BulkWrite(ctx, models); err != nil {
+		return fmt.Errorf("failed to reorder rules: %w", err)
+	}
+
+	return nil
+}
+
 func (r *Repository) RemoveRule(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -138,15 +313,26 @@ func (r *Repository) RemoveRule(id string) error {
 	return nil
 }
 
-func (r *Repository) UpdateRule(id, name, pattern string, keywords []string) (*Rule, error) {
+func (r *Repository) UpdateRule(id string, rule Rule) (*Rule, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	update := bson.M{
 		"$set": bson.M{
-			"name":     name,
-			"pattern":  pattern,
-			"keywords": keywords,
+			"name":            rule.Name,
+			"pattern":         rule.Pattern,
+			"keywords":        rule.Keywords,
+			"rate_limit":      rule.RateLimit,
+			"delivery":        rule.Delivery,
+			"schedule":        rule.Schedule,
+			"action":          rule.Action,
+			"template":        rule.Template,
+			"target":          rule.Target,
+			"muted_sources":   rule.MutedSources,
+			"stop_processing": rule.StopProcessing,
+			"accounts":        rule.Accounts,
+			"sender":          rule.Sender,
+			"message":         rule.Message,
 		},
 	}
 
@@ -172,18 +358,67 @@ func (r *Repository) UpdateRule(id, name, pattern string, keywords []string) (*R
 	return &updatedRule, nil
 }
 
+func (r *Repository) SetEnabled(id string, enabled bool, snoozedUntil *time.Time) (*Rule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"enabled": enabled}
+	update := bson.M{"$set": set}
+	if snoozedUntil != nil {
+		set["snoozed_until"] = snoozedUntil
+	} else {
+		update["$unset"] = bson.M{"snoozed_until": ""}
+	}
+
+	var updatedRule Rule
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedRule)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("rule not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to update rule: %w", err)
+	}
+
+	return &updatedRule, nil
+}
+
+func (r *Repository) ReleaseSnoozes(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"snoozed_until": bson.M{"$lte": now}},
+		bson.M{
+			"$set":   bson.M{"enabled": true},
+			"$unset": bson.M{"snoozed_until": ""},
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release snoozed rules: %w", err)
+	}
+
+	return result.ModifiedCount, nil
+}
+
 func (r *Repository) GetPatterns() ([]matcher.MatchRule, error) {
 	rules, err := r.GetRules()
 	if err != nil {
 		return nil, err
 	}
 
-	matchRules := make([]matcher.MatchRule, len(rules))
-	for i, rule := range rules {
-		matchRules[i] = matcher.MatchRule{
-			Pattern:  rule.Pattern,
-			Keywords: rule.Keywords,
+	now := time.Now()
+	matchRules := make([]matcher.MatchRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Active(now) {
+			continue
 		}
+		matchRules = append(matchRules, rule.matchRule())
 	}
 
 	return matchRules, nil
@@ -31,7 +31,9 @@ func NewRouter(svc *Service, apiToken string) *chi.Mux {
 
 	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.RealIP)
+	r.Use(middleware.Tracing)
 	r.Use(middleware.Logging)
+	r.Use(middleware.Metrics)
 	r.Use(chimiddleware.Recoverer)
 	r.Use(middleware.CORS)
 
@@ -46,9 +48,16 @@ func NewRouter(svc *Service, apiToken string) *chi.Mux {
 		r.Route("/rules", func(r chi.Router) {
 			r.Get("/", Wrap(rulesHandler.GetRules))
 			r.Put("/", WrapWithBody(rulesHandler.UpdateRules))
+			r.Put("/order", WrapWithBody(rulesHandler.ReorderRules))
 			r.Post("/add", WrapWithBody(rulesHandler.AddRule))
+			r.Post("/test", WrapWithBody(rulesHandler.TestRules))
+			r.Post("/backtest", WrapWithBody(rulesHandler.Backtest))
 			r.Delete("/remove", WrapWithBody(rulesHandler.RemoveRule))
 			r.Patch("/{id}", WrapWithBodyAndID(rulesHandler.UpdateRule))
+			r.Post("/{id}/enable", WrapWithID(rulesHandler.EnableRule))
+			r.Post("/{id}/disable", WrapWithID(rulesHandler.DisableRule))
+			r.Post("/{id}/snooze", WrapWithBodyAndID(rulesHandler.SnoozeRule))
+			r.Get("/{id}/stats", WrapWithID(rulesHandler.GetRuleStats))
 		})
 	})
 
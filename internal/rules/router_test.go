@@ -1,9 +1,12 @@
 package rules_test
 
 import (
+	"context"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
 	"github.com/gabrielmelo/tg-forward/internal/rules"
 	"github.com/gabrielmelo/tg-forward/internal/testutils"
 	"github.com/go-chi/chi/v5"
@@ -402,6 +405,28 @@ func TestUpdateRulesHandler(t *testing.T) {
 		require.Equal(t, http.StatusBadRequest, res.Code)
 		require.Equal(t, "INVALID_RULES", body.Code)
 	})
+
+	t.Run("should return 400 for invalid message condition or template", func(t *testing.T) {
+		for _, rule := range []rules.Rule{
+			{ID: "1", Name: "Bad Media", Pattern: "new.*", Message: &rules.MessageCondition{Media: []string{"hologram"}}},
+			{ID: "1", Name: "Bad Template", Pattern: "new.*", Template: "{{.Rule"},
+		} {
+			req := testutils.NewAuthenticatedRequest(
+				t,
+				"PUT",
+				"/rules",
+				testutils.MarshallBody(t, rules.UpdateRulesRequest{Rules: []rules.Rule{rule}}),
+				testAPIToken,
+			)
+
+			res := testutils.ExecuteRequest(req, r)
+
+			body := testutils.UnmarshallReqBody[rules.ApiErrorResponse](t, res.Body)
+
+			require.Equal(t, http.StatusBadRequest, res.Code, rule.Name)
+			require.Equal(t, "INVALID_RULES", body.Code, rule.Name)
+		}
+	})
 }
 
 func TestUpdateRuleHandler(t *testing.T) {
@@ -587,3 +612,407 @@ func TestUpdateRuleHandler(t *testing.T) {
 		require.Equal(t, "INVALID_RULE", body.Code)
 	})
 }
+
+func TestEnableDisableRuleHandler(t *testing.T) {
+	initialPatterns := []string{"test.*"}
+	r, repo, cleanup := setupRouter(t, initialPatterns)
+	defer cleanup()
+
+	existingRules, err := repo.GetRules()
+	require.NoError(t, err)
+	require.NotEmpty(t, existingRules)
+
+	ruleID := existingRules[0].ID
+
+	t.Run("should disable rule and skip it when matching", func(t *testing.T) {
+		req := testutils.NewAuthenticatedRequest(t, "POST", "/rules/"+ruleID+"/disable", nil, testAPIToken)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+
+		ruleMap, ok := dataMap["rule"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, false, ruleMap["enabled"])
+
+		patterns, err := repo.GetPatterns()
+		require.NoError(t, err)
+		require.Empty(t, patterns)
+	})
+
+	t.Run("should enable rule", func(t *testing.T) {
+		req := testutils.NewAuthenticatedRequest(t, "POST", "/rules/"+ruleID+"/enable", nil, testAPIToken)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+
+		ruleMap, ok := dataMap["rule"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, true, ruleMap["enabled"])
+
+		patterns, err := repo.GetPatterns()
+		require.NoError(t, err)
+		require.Len(t, patterns, 1)
+	})
+
+	t.Run("should snooze rule until the given time", func(t *testing.T) {
+		reqBody := rules.SnoozeRuleRequest{Until: time.Now().Add(time.Hour)}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/"+ruleID+"/snooze",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+
+		ruleMap, ok := dataMap["rule"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, false, ruleMap["enabled"])
+		require.NotEmpty(t, ruleMap["snoozed_until"])
+	})
+
+	t.Run("should re-enable rule once the snooze expires", func(t *testing.T) {
+		_, err := repo.SetEnabled(ruleID, false, ptr(time.Now().Add(-time.Minute)))
+		require.NoError(t, err)
+
+		released, err := repo.ReleaseSnoozes(time.Now())
+		require.NoError(t, err)
+		require.Equal(t, int64(1), released)
+
+		rule, err := repo.GetRuleByID(ruleID)
+		require.NoError(t, err)
+		require.True(t, rule.Enabled)
+		require.Nil(t, rule.SnoozedUntil)
+	})
+
+	t.Run("should return 400 for snooze in the past", func(t *testing.T) {
+		reqBody := rules.SnoozeRuleRequest{Until: time.Now().Add(-time.Hour)}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/"+ruleID+"/snooze",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.ApiErrorResponse](t, res.Body)
+
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "INVALID_SNOOZE", body.Code)
+	})
+
+	t.Run("should return 404 for nonexistent rule", func(t *testing.T) {
+		req := testutils.NewAuthenticatedRequest(t, "POST", "/rules/nonexistent-id/disable", nil, testAPIToken)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.ApiErrorResponse](t, res.Body)
+
+		require.Equal(t, http.StatusNotFound, res.Code)
+		require.Equal(t, "RULE_NOT_FOUND", body.Code)
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestReorderRulesHandler(t *testing.T) {
+	initialPatterns := []string{"first", "second", "third"}
+	r, repo, cleanup := setupRouter(t, initialPatterns)
+	defer cleanup()
+
+	t.Run("should reorder rules by the given ids", func(t *testing.T) {
+		existingRules, err := repo.GetRules()
+		require.NoError(t, err)
+		require.Len(t, existingRules, 3)
+
+		reqBody := rules.ReorderRulesRequest{IDs: []string{
+			existingRules[2].ID,
+			existingRules[0].ID,
+			existingRules[1].ID,
+		}}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"PUT",
+			"/rules/order",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		reordered, err := repo.GetRules()
+		require.NoError(t, err)
+		require.Equal(t, existingRules[2].ID, reordered[0].ID)
+		require.Equal(t, existingRules[0].ID, reordered[1].ID)
+		require.Equal(t, existingRules[1].ID, reordered[2].ID)
+	})
+
+	t.Run("should return 400 when a rule is missing from the order", func(t *testing.T) {
+		existingRules, err := repo.GetRules()
+		require.NoError(t, err)
+
+		reqBody := rules.ReorderRulesRequest{IDs: []string{existingRules[0].ID}}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"PUT",
+			"/rules/order",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.ApiErrorResponse](t, res.Body)
+
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "INVALID_ORDER", body.Code)
+	})
+}
+
+func TestTestRulesHandler(t *testing.T) {
+	initialPatterns := []string{"promo", "urgent"}
+	r, _, cleanup := setupRouter(t, initialPatterns)
+	defer cleanup()
+
+	t.Run("should return matching rules, normalized text and spans", func(t *testing.T) {
+		reqBody := rules.TestRulesRequest{Text: "Promoção URGENTE!"}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/test",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, "promocao urgente", dataMap["normalized"])
+
+		matches, ok := dataMap["matches"].([]interface{})
+		require.True(t, ok)
+		require.Len(t, matches, 2)
+
+		first := matches[0].(map[string]interface{})
+		spans := first["spans"].([]interface{})
+		require.Len(t, spans, 1)
+		require.Equal(t, "promo", spans[0].(map[string]interface{})["text"])
+	})
+
+	t.Run("should include a candidate rule that is not saved", func(t *testing.T) {
+		reqBody := rules.TestRulesRequest{
+			Text: "new release today",
+			Rule: &rules.AddRuleRequest{Name: "Releases", Keywords: []string{"release"}},
+		}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/test",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, []interface{}{rules.CandidateRuleID}, dataMap["matched"])
+	})
+
+	t.Run("should return 400 for an invalid candidate rule", func(t *testing.T) {
+		reqBody := rules.TestRulesRequest{
+			Text: "anything",
+			Rule: &rules.AddRuleRequest{Name: "Broken", Pattern: "[invalid"},
+		}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/test",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, r)
+
+		body := testutils.UnmarshallReqBody[rules.ApiErrorResponse](t, res.Body)
+
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "INVALID_RULE", body.Code)
+	})
+}
+
+type fakeHistory []rules.HistoryMessage
+
+func (f fakeHistory) History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(rules.HistoryMessage) error) error {
+	for _, msg := range f {
+		if msg.Chat != chat || msg.Date.Before(since) || msg.Date.After(until) {
+			continue
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type failingHistory struct {
+	err error
+}
+
+func (f failingHistory) History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(rules.HistoryMessage) error) error {
+	return f.err
+}
+
+func TestBacktestHandler(t *testing.T) {
+	client, database, cleanup := testutils.SetupTestDB(t)
+	defer cleanup()
+
+	fixture := testutils.NewFixture(t, client, database, testAPIToken, []string{"promo"})
+
+	now := time.Now()
+	fixture.RulesService.SetHistorySource(fakeHistory{
+		{Chat: "deals", ID: 1, Date: now.Add(-2 * time.Hour), Message: &matcher.Message{Text: "promo hoje"}},
+		{Chat: "deals", ID: 2, Date: now.Add(-time.Hour), Message: &matcher.Message{Text: "nothing here"}},
+		{Chat: "deals", ID: 3, Date: now.Add(-30 * 24 * time.Hour), Message: &matcher.Message{Text: "old promo"}},
+	})
+
+	t.Run("should report hits per rule for saved rules", func(t *testing.T) {
+		reqBody := rules.BacktestRequest{Chats: []string{"deals"}, Since: now.Add(-7 * 24 * time.Hour)}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/backtest",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, fixture.Router)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, float64(2), dataMap["scanned"])
+		require.Equal(t, float64(1), dataMap["matched"])
+
+		ruleReports := dataMap["rules"].([]interface{})
+		require.Len(t, ruleReports, 1)
+		report := ruleReports[0].(map[string]interface{})
+		require.Equal(t, float64(1), report["hits"])
+		require.Len(t, report["samples"], 1)
+	})
+
+	t.Run("should backtest candidate rules", func(t *testing.T) {
+		reqBody := rules.BacktestRequest{
+			Chats: []string{"deals"},
+			Since: now.Add(-7 * 24 * time.Hour),
+			Rules: []rules.AddRuleRequest{{Name: "Nothing", Keywords: []string{"nothing"}}},
+		}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/backtest",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, fixture.Router)
+
+		body := testutils.UnmarshallReqBody[rules.DataResponse](t, res.Body)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		dataMap, ok := body.Data.(map[string]interface{})
+		require.True(t, ok)
+
+		report := dataMap["rules"].([]interface{})[0].(map[string]interface{})
+		require.Equal(t, "Nothing", report["rule_name"])
+		require.Equal(t, float64(1), report["hits"])
+	})
+
+	t.Run("should return 400 without chats", func(t *testing.T) {
+		reqBody := rules.BacktestRequest{Since: now.Add(-time.Hour)}
+
+		req := testutils.NewAuthenticatedRequest(
+			t,
+			"POST",
+			"/rules/backtest",
+			testutils.MarshallBody(t, reqBody),
+			testAPIToken,
+		)
+
+		res := testutils.ExecuteRequest(req, fixture.Router)
+
+		body := testutils.UnmarshallReqBody[rules.ApiErrorResponse](t, res.Body)
+
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "INVALID_BACKTEST", body.Code)
+	})
+
+	t.Run("should return 503 without a connected client or 504 on timeout", func(t *testing.T) {
+		for err, expected := range map[error]int{
+			rules.ErrBacktestUnavailable: http.StatusServiceUnavailable,
+			context.DeadlineExceeded: http.StatusGatewayTimeout,
+		} {
+			fixture.RulesService.SetHistorySource(failingHistory{err: err})
+
+			req := testutils.NewAuthenticatedRequest(
+				t,
+				"POST",
+				"/rules/backtest",
+				testutils.MarshallBody(t, rules.BacktestRequest{Chats: []string{"deals"}, Since: now.Add(-time.Hour)}),
+				testAPIToken,
+			)
+
+			res := testutils.ExecuteRequest(req, fixture.Router)
+
+			require.Equal(t, expected, res.Code, err.Error())
+		}
+	})
+}
@@ -1,22 +1,53 @@
 package rules
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/gabrielmelo/tg-forward/internal/matcher"
 )
 
+const snoozeCheckInterval = 30 * time.Second
+
+var (
+	ErrBacktestUnavailable = errors.New("backtest is not available without a connected user client")
+	ErrBacktestTimeout     = errors.New("backtest did not finish in time, narrow the time range or lower the limit")
+)
+
 type Service struct {
-	repo    *Repository
+	repo     *Repository
+	current  atomic.Pointer[snapshot]
+	reloadMu sync.Mutex
+	history  HistorySource
+	links    LinkResolver
+	stats    StatsSource
+	targets  []string
+}
+
+type snapshot struct {
 	matcher *matcher.Matcher
+	index   map[string]Rule
 }
 
 func NewService(repo *Repository, m *matcher.Matcher) *Service {
-	return &Service{
-		repo:    repo,
-		matcher: m,
+	s := &Service{repo: repo}
+
+	index := map[string]Rule{}
+	if rules, err := repo.GetRules(); err == nil {
+		index = indexRules(rules)
 	}
+	s.current.Store(&snapshot{matcher: m, index: index})
+
+	return s
 }
 
 func (s *Service) GetRules() []Rule {
@@ -24,64 +55,95 @@ func (s *Service) GetRules() []Rule {
 	return rules
 }
 
+func (s *Service) GetRule(id string) (Rule, bool) {
+	rule, ok := s.current.Load().index[id]
+	return rule, ok
+}
+
 func (s *Service) UpdateRules(rules []Rule) ([]Rule, error) {
 	if len(rules) == 0 {
 		return nil, fmt.Errorf("at least one rule is required")
 	}
 
-	matchRules := make([]matcher.MatchRule, len(rules))
 	for i, rule := range rules {
-		if rule.Pattern != "" {
-			if err := s.validatePattern(rule.Pattern); err != nil {
-				return nil, err
-			}
-			matchRules[i] = matcher.MatchRule{Pattern: rule.Pattern}
-		} else if len(rule.Keywords) > 0 {
-			matchRules[i] = matcher.MatchRule{Keywords: rule.Keywords}
-		} else {
-			return nil, fmt.Errorf("rule must have either pattern or keywords")
+		if err := s.validateRule(rule); err != nil {
+			return nil, err
 		}
+		rules[i].Priority = i
+	}
+
+	if _, err := buildSnapshot(rules, time.Now()); err != nil {
+		return nil, err
 	}
 
 	if err := s.repo.SetRules(rules); err != nil {
 		return nil, fmt.Errorf("failed to save rules: %w", err)
 	}
 
-	newMatcher, err := matcher.New(matchRules)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create matcher: %w", err)
+	if err := s.reload(); err != nil {
+		return nil, err
 	}
-	s.matcher = newMatcher
 
 	return rules, nil
 }
 
-func (s *Service) AddRule(name, pattern string, keywords []string) (*Rule, error) {
-	if pattern != "" {
-		if err := s.validatePattern(pattern); err != nil {
-			return nil, err
-		}
-	} else if len(keywords) == 0 {
-		return nil, fmt.Errorf("rule must have either pattern or keywords")
+func (s *Service) AddRule(rule Rule) (*Rule, error) {
+	if err := s.validateRule(rule); err != nil {
+		return nil, err
 	}
 
-	if name == "" {
-		return nil, fmt.Errorf("rule name is required")
+	rule.Enabled = true
+	rule.SnoozedUntil = nil
+
+	if err := s.prepare(func(rules []Rule) []Rule {
+		return append(rules, rule)
+	}); err != nil {
+		return nil, err
 	}
 
-	rule, err := s.repo.AddRule(name, pattern, keywords)
+	added, err := s.repo.AddRule(rule)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add rule: %w", err)
 	}
 
-	matchRules, _ := s.repo.GetPatterns()
-	newMatcher, err := matcher.New(matchRules)
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+func (s *Service) ReorderRules(ids []string) ([]Rule, error) {
+	existing, err := s.repo.GetRules()
 	if err != nil {
-		return nil, fmt.Errorf("failed to update matcher: %w", err)
+		return nil, fmt.Errorf("failed to load rules: %w", err)
 	}
-	s.matcher = newMatcher
 
-	return rule, nil
+	if len(ids) != len(existing) {
+		return nil, fmt.Errorf("order must list all %d rules exactly once", len(existing))
+	}
+
+	known := indexRules(existing)
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := known[id]; !ok {
+			return nil, fmt.Errorf("rule not found: %s", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("rule listed more than once: %s", id)
+		}
+		seen[id] = true
+	}
+
+	if err := s.repo.SetOrder(ids); err != nil {
+		return nil, err
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetRules()
 }
 
 func (s *Service) RemoveRule(id string) error {
@@ -89,46 +151,269 @@ func (s *Service) RemoveRule(id string) error {
 		return err
 	}
 
-	patterns, _ := s.repo.GetPatterns()
-	newMatcher, err := matcher.New(patterns)
-	if err != nil {
-		return fmt.Errorf("failed to update matcher: %w", err)
-	}
-	s.matcher = newMatcher
-
-	return nil
+	return s.reload()
 }
 
-func (s *Service) UpdateRule(id, name, pattern string, keywords []string) (*Rule, error) {
-	if name == "" {
+func (s *Service) UpdateRule(id string, rule Rule) (*Rule, error) {
+	if rule.Name == "" {
 		return nil, fmt.Errorf("rule name is required")
 	}
 
-	if pattern != "" {
-		if err := s.validatePattern(pattern); err != nil {
-			return nil, err
+	if err := s.validateRule(rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.prepare(func(rules []Rule) []Rule {
+		for i, existing := range rules {
+			if existing.ID != id {
+				continue
+			}
+			next := rule
+			next.ID = id
+			next.Enabled = existing.Enabled
+			next.SnoozedUntil = existing.SnoozedUntil
+			rules[i] = next
 		}
-	} else if len(keywords) == 0 {
-		return nil, fmt.Errorf("rule must have either pattern or keywords")
+		return rules
+	}); err != nil {
+		return nil, err
 	}
 
-	rule, err := s.repo.UpdateRule(id, name, pattern, keywords)
+	updated, err := s.repo.UpdateRule(id, rule)
 	if err != nil {
 		return nil, err
 	}
 
-	patterns, _ := s.repo.GetPatterns()
-	newMatcher, err := matcher.New(patterns)
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (s *Service) EnableRule(id string) (*Rule, error) {
+	return s.setEnabled(id, true, nil)
+}
+
+func (s *Service) DisableRule(id string) (*Rule, error) {
+	return s.setEnabled(id, false, nil)
+}
+
+func (s *Service) SnoozeRule(id string, until time.Time) (*Rule, error) {
+	return s.setEnabled(id, false, &until)
+}
+
+func (s *Service) setEnabled(id string, enabled bool, snoozedUntil *time.Time) (*Rule, error) {
+	rule, err := s.repo.SetEnabled(id, enabled, snoozedUntil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update matcher: %w", err)
+		return nil, err
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
 	}
-	s.matcher = newMatcher
 
 	return rule, nil
 }
 
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(snoozeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := s.repo.ReleaseSnoozes(time.Now())
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to release snoozed rules", "error", err)
+				continue
+			}
+			if released == 0 {
+				continue
+			}
+
+			slog.InfoContext(ctx, "Re-enabled snoozed rules", "count", released)
+			if err := s.reload(); err != nil {
+				slog.ErrorContext(ctx, "Failed to reload rules", "error", err)
+			}
+		}
+	}
+}
+
+func (s *Service) TestRules(text string, candidate *Rule) (*TestRulesResponse, error) {
+	existing, err := s.repo.GetRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules: %w", err)
+	}
+
+	if candidate != nil {
+		if candidate.Name == "" {
+			candidate.Name = CandidateRuleID
+		}
+		if err := s.validateRule(*candidate); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	names := map[string]string{}
+	matchRules := make([]matcher.MatchRule, 0, len(existing)+1)
+	replaced := false
+	for _, rule := range existing {
+		if candidate != nil && rule.ID == candidate.ID {
+			rule = *candidate
+			replaced = true
+		} else if !rule.Active(now) {
+			continue
+		}
+		names[rule.ID] = rule.Name
+		matchRules = append(matchRules, rule.matchRule())
+	}
+
+	if candidate != nil && !replaced {
+		candidate.ID = CandidateRuleID
+		names[candidate.ID] = candidate.Name
+		matchRules = append(matchRules, candidate.matchRule())
+	}
+
+	m, err := matcher.New(matchRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create matcher: %w", err)
+	}
+
+	explanation := m.Explain(&matcher.Message{Text: text})
+	response := &TestRulesResponse{
+		Normalized: explanation.Normalized,
+		Matched:    explanation.Matched,
+		Matches:    make([]RuleMatch, 0, len(explanation.Rules)),
+	}
+	if response.Matched == nil {
+		response.Matched = []string{}
+	}
+
+	for _, rule := range explanation.Rules {
+		spans := make([]MatchSpan, 0, len(rule.Spans))
+		for _, span := range rule.Spans {
+			spans = append(spans, MatchSpan{
+				Start: span.Start,
+				End:   span.End,
+				Text:  string([]rune(explanation.Normalized)[span.Start:span.End]),
+			})
+		}
+		response.Matches = append(response.Matches, RuleMatch{
+			RuleID:        rule.ID,
+			RuleName:      names[rule.ID],
+			Spans:         spans,
+			ConditionsMet: rule.ConditionsMet,
+		})
+	}
+
+	return response, nil
+}
+
 func (s *Service) GetMatcher() *matcher.Matcher {
-	return s.matcher
+	return s.current.Load().matcher
+}
+
+func (s *Service) prepare(change func(rules []Rule) []Rule) error {
+	rules, err := s.repo.GetRules()
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+
+	_, err = buildSnapshot(change(rules), time.Now())
+	return err
+}
+
+func (s *Service) reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	rules, err := s.repo.GetRules()
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+
+	return s.publish(rules)
+}
+
+func (s *Service) publish(rules []Rule) error {
+	next, err := buildSnapshot(rules, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.current.Store(next)
+	return nil
+}
+
+func buildSnapshot(rules []Rule, now time.Time) (*snapshot, error) {
+	matchRules := make([]matcher.MatchRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Active(now) {
+			matchRules = append(matchRules, rule.matchRule())
+		}
+	}
+
+	m, err := matcher.New(matchRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create matcher: %w", err)
+	}
+
+	return &snapshot{matcher: m, index: indexRules(rules)}, nil
+}
+
+func (s *Service) validateRule(rule Rule) error {
+	if rule.Pattern != "" {
+		if err := s.validatePattern(rule.Pattern); err != nil {
+			return err
+		}
+	} else if len(rule.Keywords) == 0 {
+		return fmt.Errorf("rule must have either pattern or keywords")
+	}
+
+	if rule.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+
+	if err := validateRateLimit(rule.RateLimit); err != nil {
+		return err
+	}
+
+	if err := validateDelivery(rule.Delivery); err != nil {
+		return err
+	}
+
+	if err := validateSchedule(rule.Schedule); err != nil {
+		return err
+	}
+
+	if err := validateAction(rule.Action); err != nil {
+		return err
+	}
+
+	if err := validateMessageCondition(rule.Message); err != nil {
+		return err
+	}
+
+	if err := s.validateTarget(rule.Target); err != nil {
+		return err
+	}
+
+	return validateTemplate(rule.Template)
+}
+
+func (s *Service) SetTargets(targets []string) {
+	s.targets = targets
+}
+
+func (s *Service) validateTarget(target string) error {
+	if target == "" || len(s.targets) == 0 || slices.Contains(s.targets, target) {
+		return nil
+	}
+	return fmt.Errorf("unknown delivery target %q", target)
 }
 
 func (s *Service) validatePattern(pattern string) error {
@@ -137,3 +422,107 @@ func (s *Service) validatePattern(pattern string) error {
 	}
 	return nil
 }
+
+func validateRateLimit(limit *RateLimit) error {
+	if limit == nil {
+		return nil
+	}
+	if limit.PerMinute < 0 || limit.Burst < 0 || limit.CooldownSeconds < 0 {
+		return fmt.Errorf("rate limit values must not be negative")
+	}
+	switch limit.Overflow {
+	case "", OverflowDrop, OverflowQueue, OverflowCollapse:
+		return nil
+	default:
+		return fmt.Errorf("invalid overflow policy '%s': must be drop, queue or collapse", limit.Overflow)
+	}
+}
+
+func validateDelivery(schedule *DeliverySchedule) error {
+	if schedule == nil {
+		return nil
+	}
+
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone '%s': %w", schedule.Timezone, err)
+	}
+
+	switch schedule.Mode {
+	case "", DeliveryImmediate:
+		return nil
+	case DeliveryInterval:
+		if schedule.IntervalMinutes <= 0 {
+			return fmt.Errorf("interval delivery requires interval_minutes greater than zero")
+		}
+		return nil
+	case DeliveryDaily:
+		if _, err := time.Parse("15:04", schedule.At); err != nil {
+			return fmt.Errorf("invalid daily delivery time '%s': must be HH:MM", schedule.At)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid delivery mode '%s': must be immediate, interval or daily", schedule.Mode)
+	}
+}
+
+func validateSchedule(schedule *ActiveSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone '%s': %w", schedule.Timezone, err)
+	}
+
+	if len(schedule.Windows) == 0 {
+		return fmt.Errorf("schedule must have at least one window")
+	}
+
+	for _, window := range schedule.Windows {
+		if _, err := time.Parse("15:04", window.Start); err != nil {
+			return fmt.Errorf("invalid window start '%s': must be HH:MM", window.Start)
+		}
+		if _, err := time.Parse("15:04", window.End); err != nil {
+			return fmt.Errorf("invalid window end '%s': must be HH:MM", window.End)
+		}
+		for _, day := range window.Days {
+			if _, ok := Weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("invalid window day '%s': must be one of mon, tue, wed, thu, fri, sat, sun", day)
+			}
+		}
+	}
+
+	switch schedule.Outside {
+	case "", OutsideDrop, OutsideDefer, OutsideSilent:
+		return nil
+	default:
+		return fmt.Errorf("invalid outside policy '%s': must be drop, defer or silent", schedule.Outside)
+	}
+}
+
+func validateAction(action RuleAction) error {
+	switch action {
+	case "", ActionForward, ActionSuppress:
+		return nil
+	default:
+		return fmt.Errorf("invalid action '%s': must be forward or suppress", action)
+	}
+}
+
+func validateTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	if _, err := template.New("rule").Parse(text); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return nil
+}
+
+func indexRules(rules []Rule) map[string]Rule {
+	index := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		index[rule.ID] = rule
+	}
+	return index
+}
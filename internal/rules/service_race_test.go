@@ -0,0 +1,71 @@
+package rules_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentRuleUpdates(t *testing.T) {
+	client, database, cleanup := testutils.SetupTestDB(t)
+	defer cleanup()
+
+	fixture := testutils.NewFixture(t, client, database, testAPIToken, []string{"deal"})
+	svc := fixture.RulesService
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				for _, id := range svc.GetMatcher().MatchMessage(&matcher.Message{Text: "deal promo"}) {
+					svc.GetRule(id)
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for w := range 4 {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for i := range 10 {
+				added, err := svc.AddRule(rules.Rule{Name: fmt.Sprintf("rule-%d-%d", w, i), Keywords: []string{"promo"}})
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, err = svc.DisableRule(added.ID)
+				assert.NoError(t, err)
+				_, err = svc.EnableRule(added.ID)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	matched := svc.GetMatcher().MatchMessage(&matcher.Message{Text: "promo"})
+	require.Len(t, matched, 40)
+	for _, id := range matched {
+		rule, ok := svc.GetRule(id)
+		require.True(t, ok)
+		require.True(t, rule.Enabled)
+	}
+}
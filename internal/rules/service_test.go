@@ -0,0 +1,133 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotSwap(t *testing.T) {
+	s := &Service{}
+	require.NoError(t, s.publish([]Rule{{ID: "rule-0", Keywords: []string{"deal"}, Enabled: true}}))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				ids := s.GetMatcher().MatchMessage(&matcher.Message{Text: "deal of the day"})
+				if !assert.Len(t, ids, 1) {
+					return
+				}
+				_, _ = s.GetRule(ids[0])
+			}
+		}()
+	}
+
+	for i := 1; i <= 200; i++ {
+		rules := []Rule{{ID: fmt.Sprintf("rule-%d", i), Keywords: []string{"deal"}, Enabled: true}}
+		require.NoError(t, s.publish(rules))
+	}
+	close(stop)
+	wg.Wait()
+
+	require.Equal(t, []string{"rule-200"}, s.GetMatcher().MatchMessage(&matcher.Message{Text: "deal"}))
+	_, ok := s.GetRule("rule-200")
+	require.True(t, ok)
+}
+
+func TestBuildSnapshot(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	t.Run("should only match active rules but index all of them", func(t *testing.T) {
+		snap, err := buildSnapshot([]Rule{
+			{ID: "enabled", Keywords: []string{"deal"}, Enabled: true},
+			{ID: "disabled", Keywords: []string{"deal"}},
+			{ID: "snooze-over", Keywords: []string{"deal"}, SnoozedUntil: &past},
+			{ID: "snoozed", Keywords: []string{"deal"}, SnoozedUntil: &future},
+		}, now)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"enabled", "snooze-over"}, snap.matcher.MatchMessage(&matcher.Message{Text: "deal"}))
+		require.Len(t, snap.index, 4)
+	})
+
+	t.Run("should fail without replacing the published snapshot", func(t *testing.T) {
+		s := &Service{}
+		require.NoError(t, s.publish([]Rule{{ID: "ok", Pattern: "deal", Enabled: true}}))
+
+		err := s.publish([]Rule{{ID: "broken", Pattern: "(", Enabled: true}})
+		require.Error(t, err)
+
+		require.Equal(t, []string{"ok"}, s.GetMatcher().MatchMessage(&matcher.Message{Text: "deal"}))
+	})
+}
+
+type historyFunc func(fn func(HistoryMessage) error) error
+
+func (f historyFunc) History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(HistoryMessage) error) error {
+	return f(fn)
+}
+
+type linkMap map[string]string
+
+func (l linkMap) ResolveAll(ctx context.Context, urls []string) []string {
+	resolved := make([]string, len(urls))
+	for i, u := range urls {
+		resolved[i] = u
+		if target, ok := l[u]; ok {
+			resolved[i] = target
+		}
+	}
+	return resolved
+}
+
+func TestBacktest(t *testing.T) {
+	now := time.Now()
+	s := &Service{}
+	s.SetHistorySource(historyFunc(func(fn func(HistoryMessage) error) error {
+		for i, msg := range []*matcher.Message{
+			{Media: "photo"},
+			{Text: "oferta", URLs: []string{"https://amzn.to/abc"}},
+			{Text: "oferta", URLs: []string{"https://example.com/abc"}},
+		} {
+			if err := fn(HistoryMessage{Chat: "deals", ID: i + 1, Date: now.Add(-time.Minute), Message: msg}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	s.SetLinkResolver(linkMap{"https://amzn.to/abc": "https://www.amazon.com.br/dp/B0"})
+
+	response, err := s.Backtest(context.Background(), BacktestRequest{
+		Chats: []string{"deals"},
+		Since: now.Add(-time.Hour),
+		Rules: []AddRuleRequest{
+			{Name: "Photos", Pattern: ".*", Message: &MessageCondition{Media: []string{"photo"}}},
+			{Name: "Amazon", Keywords: []string{"oferta"}, Message: &MessageCondition{LinkDomains: []string{"amazon.com.br"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 3, response.Scanned)
+	require.Equal(t, 1, response.Rules[0].Hits)
+	require.Equal(t, 1, response.Rules[1].Hits)
+	require.Equal(t, 2, response.Rules[1].Samples[0].MessageID)
+}
@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultStatsRange = 30 * 24 * time.Hour
+
+var ErrStatsUnavailable = errors.New("rule statistics are not available")
+
+type StatsBucket string
+
+const (
+	BucketHour StatsBucket = "hour"
+	BucketDay  StatsBucket = "day"
+)
+
+type RuleStats struct {
+	Matches       int64      `json:"matches"`
+	Deliveries    int64      `json:"deliveries"`
+	Failures      int64      `json:"failures"`
+	Suppressed    int64      `json:"suppressed"`
+	Collapsed     int64      `json:"collapsed"`
+	Dropped       int64      `json:"dropped"`
+	NotRelevant   int64      `json:"not_relevant"`
+	Muted         int64      `json:"muted"`
+	Precision     *float64   `json:"precision,omitempty"`
+	LastMatchedAt *time.Time `json:"last_matched_at,omitempty"`
+}
+
+type StatsPoint struct {
+	Start      time.Time `json:"start"`
+	Matches    int64     `json:"matches"`
+	Deliveries int64     `json:"deliveries"`
+	Failures   int64     `json:"failures"`
+}
+
+type StatsSource interface {
+	RuleStats(ruleID string, since time.Time) (map[string]*RuleStats, error)
+	RuleSeries(ruleID string, since time.Time, bucket StatsBucket) ([]StatsPoint, error)
+}
+
+func (s *Service) SetStatsSource(stats StatsSource) {
+	s.stats = stats
+}
+
+func (s *Service) Stats(since time.Time) (map[string]*RuleStats, error) {
+	if s.stats == nil {
+		return nil, ErrStatsUnavailable
+	}
+	return s.collectStats("", since)
+}
+
+func (s *Service) collectStats(ruleID string, since time.Time) (map[string]*RuleStats, error) {
+	stats, err := s.stats.RuleStats(ruleID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback, err := s.repo.FeedbackCounts(ruleID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	for ruleID, kinds := range feedback {
+		st, ok := stats[ruleID]
+		if !ok {
+			st = &RuleStats{}
+			stats[ruleID] = st
+		}
+		st.NotRelevant = kinds[FeedbackNotRelevant]
+		st.Muted = kinds[FeedbackMuted] + kinds[FeedbackMutedSource]
+	}
+
+	for _, st := range stats {
+		st.Precision = precision(st)
+	}
+
+	return stats, nil
+}
+
+func (s *Service) RuleStats(id string, since time.Time, bucket StatsBucket) (*RuleStatsResponse, error) {
+	if s.stats == nil {
+		return nil, ErrStatsUnavailable
+	}
+
+	if bucket != BucketHour && bucket != BucketDay {
+		return nil, fmt.Errorf("bucket must be %q or %q", BucketHour, BucketDay)
+	}
+
+	stats, err := s.collectStats(id, since)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := s.stats.RuleSeries(id, since, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RuleStatsResponse{
+		RuleID: id,
+		Since:  since,
+		Bucket: bucket,
+		Stats:  RuleStats{},
+		Series: series,
+	}
+	if st, ok := stats[id]; ok {
+		response.Stats = *st
+	}
+
+	return response, nil
+}
+
+func precision(st *RuleStats) *float64 {
+	if st.Deliveries == 0 {
+		return nil
+	}
+
+	negative := min(st.NotRelevant+st.Muted, st.Deliveries)
+	p := float64(st.Deliveries-negative) / float64(st.Deliveries)
+	return &p
+}
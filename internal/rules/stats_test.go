@@ -0,0 +1,19 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecision(t *testing.T) {
+	require.Nil(t, precision(&RuleStats{Matches: 3, Suppressed: 3}))
+
+	p := precision(&RuleStats{Deliveries: 10, NotRelevant: 2, Muted: 1})
+	require.NotNil(t, p)
+	require.InDelta(t, 0.7, *p, 1e-9)
+
+	p = precision(&RuleStats{Deliveries: 2, NotRelevant: 5})
+	require.NotNil(t, p)
+	require.Zero(t, *p)
+}
@@ -1,5 +1,7 @@
 package rules
 
+import "time"
+
 type DataResponse struct {
 	Data any `json:"data"`
 }
@@ -9,7 +11,16 @@ type HealthResponse struct {
 }
 
 type RulesResponse struct {
-	Rules []Rule `json:"rules"`
+	Rules []Rule                `json:"rules"`
+	Stats map[string]*RuleStats `json:"stats,omitempty"`
+}
+
+type RuleStatsResponse struct {
+	RuleID string       `json:"rule_id"`
+	Since  time.Time    `json:"since"`
+	Bucket StatsBucket  `json:"bucket"`
+	Stats  RuleStats    `json:"stats"`
+	Series []StatsPoint `json:"series"`
 }
 
 type RuleResponse struct {
@@ -21,19 +32,111 @@ type UpdateRulesRequest struct {
 }
 
 type AddRuleRequest struct {
-	Name     string   `json:"name"`
-	Pattern  string   `json:"pattern"`
-	Keywords []string `json:"keywords"`
+	Name           string            `json:"name"`
+	Pattern        string            `json:"pattern"`
+	Keywords       []string          `json:"keywords"`
+	RateLimit      *RateLimit        `json:"rate_limit"`
+	Delivery       *DeliverySchedule `json:"delivery"`
+	Schedule       *ActiveSchedule   `json:"schedule"`
+	StopProcessing bool              `json:"stop_processing"`
+	Action         RuleAction        `json:"action"`
+	Template       string            `json:"template"`
+	Target         string            `json:"target"`
+	MutedSources   []int64           `json:"muted_sources"`
+	Accounts       []string          `json:"accounts"`
+	Sender         *SenderCondition  `json:"sender"`
+	Message        *MessageCondition `json:"message"`
+}
+
+func (r AddRuleRequest) toRule() Rule {
+	return Rule{
+		Name:           r.Name,
+		Pattern:        r.Pattern,
+		Keywords:       r.Keywords,
+		RateLimit:      r.RateLimit,
+		Delivery:       r.Delivery,
+		Schedule:       r.Schedule,
+		StopProcessing: r.StopProcessing,
+		Action:         r.Action,
+		Template:       r.Template,
+		Target:         r.Target,
+		MutedSources:   r.MutedSources,
+		Accounts:       r.Accounts,
+		Sender:         r.Sender,
+		Message:        r.Message,
+	}
 }
 
 type RemoveRuleRequest struct {
 	ID string `json:"id"`
 }
 
-type UpdateRuleRequest struct {
-	Name     string   `json:"name"`
-	Pattern  string   `json:"pattern"`
-	Keywords []string `json:"keywords"`
+type UpdateRuleRequest AddRuleRequest
+
+type ReorderRulesRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type SnoozeRuleRequest struct {
+	Until time.Time `json:"until"`
+}
+
+const CandidateRuleID = "candidate"
+
+type TestRulesRequest struct {
+	Text   string          `json:"text"`
+	RuleID string          `json:"rule_id"`
+	Rule   *AddRuleRequest `json:"rule"`
+}
+
+type TestRulesResponse struct {
+	Normalized string      `json:"normalized"`
+	Matched    []string    `json:"matched"`
+	Matches    []RuleMatch `json:"matches"`
+}
+
+type RuleMatch struct {
+	RuleID        string      `json:"rule_id"`
+	RuleName      string      `json:"rule_name"`
+	Spans         []MatchSpan `json:"spans"`
+	ConditionsMet bool        `json:"conditions_met"`
+}
+
+type MatchSpan struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+type BacktestRequest struct {
+	Chats   []string         `json:"chats"`
+	Since   time.Time        `json:"since"`
+	Until   time.Time        `json:"until"`
+	Rules   []AddRuleRequest `json:"rules"`
+	Limit   int              `json:"limit"`
+	Samples int              `json:"samples"`
+}
+
+type BacktestResponse struct {
+	Since   time.Time       `json:"since"`
+	Until   time.Time       `json:"until"`
+	Scanned int             `json:"scanned"`
+	Matched int             `json:"matched"`
+	Rules   []*RuleBacktest `json:"rules"`
+}
+
+type RuleBacktest struct {
+	RuleID   string           `json:"rule_id"`
+	RuleName string           `json:"rule_name"`
+	Hits     int              `json:"hits"`
+	Samples  []BacktestSample `json:"samples"`
+}
+
+type BacktestSample struct {
+	Chat      string    `json:"chat"`
+	MessageID int       `json:"message_id"`
+	Date      time.Time `json:"date"`
+	Text      string    `json:"text"`
 }
 
 type ErrorResponse struct {
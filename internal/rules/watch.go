@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	pollInterval                = 5 * time.Second
+	watchRetryDelay             = 5 * time.Second
+	changeStreamCode            = 40573
+	transactionsUnsupportedCode = 20
+)
+
+var ErrChangeStreamUnsupported = errors.New("change streams are not supported by this MongoDB deployment")
+
+func (r *Repository) Watch(ctx context.Context, onChange func()) error {
+	stream, err := r.collection.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamCode) {
+			return ErrChangeStreamUnsupported
+		}
+		return fmt.Errorf("failed to watch rules: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	onChange()
+
+	for stream.Next(ctx) {
+		for stream.TryNext(ctx) {
+		}
+		if err := stream.Err(); err != nil {
+			return err
+		}
+		onChange()
+	}
+
+	return stream.Err()
+}
+
+func (s *Service) Watch(ctx context.Context) {
+	onChange := func() {
+		if err := s.reload(); err != nil {
+			slog.ErrorContext(ctx, "Failed to reload rules", "error", err)
+		}
+	}
+
+	for {
+		err := s.repo.Watch(ctx, onChange)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, ErrChangeStreamUnsupported) {
+			slog.InfoContext(ctx, "Polling for rule changes", "reason", err, "interval", pollInterval)
+			s.poll(ctx, onChange)
+			return
+		}
+
+		if err == nil {
+			slog.InfoContext(ctx, "Rule change stream was invalidated, reopening")
+			continue
+		}
+
+		slog.WarnContext(ctx, "Rule change stream stopped, restarting", "error", err, "delay", watchRetryDelay)
+		if !wait(ctx, watchRetryDelay) {
+			return
+		}
+	}
+}
+
+func (s *Service) poll(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			onChange()
+		}
+	}
+}
+
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,88 @@
+package rules_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/rules"
+	"github.com/gabrielmelo/tg-forward/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWatchRules(t *testing.T) {
+	setups := map[string]func(t *testing.T) (*mongo.Client, string, func()){
+		"change stream": testutils.SetupReplicaSetDB,
+		"polling":       testutils.SetupTestDB,
+	}
+
+	for name, setup := range setups {
+		t.Run("should converge replicas using "+name, func(t *testing.T) {
+			client, database, cleanup := setup(t)
+			defer cleanup()
+
+			primary := testutils.NewFixture(t, client, database, testAPIToken, nil)
+			replica := testutils.NewFixture(t, client, database, testAPIToken, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go replica.RulesService.Watch(ctx)
+
+			matches := func(text string) func() bool {
+				return func() bool {
+					return len(replica.RulesService.GetMatcher().MatchMessage(&matcher.Message{Text: text})) > 0
+				}
+			}
+
+			added, err := primary.RulesService.AddRule(rules.Rule{Name: "Deals", Keywords: []string{"promo"}})
+			require.NoError(t, err)
+			require.Eventually(t, matches("promo today"), 15*time.Second, 100*time.Millisecond)
+
+			_, err = client.Database(database).Collection("rules").UpdateOne(
+				context.Background(),
+				bson.M{"_id": added.ID},
+				bson.M{"$set": bson.M{"enabled": false}},
+			)
+			require.NoError(t, err)
+			require.Eventually(t, func() bool { return !matches("promo today")() }, 15*time.Second, 100*time.Millisecond)
+
+			rule, ok := replica.RulesService.GetRule(added.ID)
+			require.True(t, ok)
+			require.False(t, rule.Enabled)
+		})
+	}
+}
+
+func TestWatchRulesReplaceAll(t *testing.T) {
+	client, database, cleanup := testutils.SetupReplicaSetDB(t)
+	defer cleanup()
+
+	primary := testutils.NewFixture(t, client, database, testAPIToken, nil)
+	replica := testutils.NewFixture(t, client, database, testAPIToken, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go replica.RulesService.Watch(ctx)
+
+	matches := func(text string) func() bool {
+		return func() bool {
+			return len(replica.RulesService.GetMatcher().MatchMessage(&matcher.Message{Text: text})) > 0
+		}
+	}
+
+	_, err := primary.RulesService.AddRule(rules.Rule{Name: "Deals", Keywords: []string{"promo"}})
+	require.NoError(t, err)
+	require.Eventually(t, matches("promo today"), 15*time.Second, 100*time.Millisecond)
+
+	_, err = primary.RulesService.UpdateRules([]rules.Rule{{ID: "coupons", Name: "Coupons", Keywords: []string{"cupom"}, Enabled: true}})
+	require.NoError(t, err)
+	require.Eventually(t, matches("cupom hoje"), 2*time.Second, 50*time.Millisecond)
+	require.False(t, matches("promo today")())
+
+	_, err = primary.RulesService.AddRule(rules.Rule{Name: "Offers", Keywords: []string{"oferta"}})
+	require.NoError(t, err)
+	require.Eventually(t, matches("oferta hoje"), 2*time.Second, 50*time.Millisecond)
+}
@@ -61,6 +61,38 @@ func WrapWithBody[Req any, Res any](h func(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+func WrapWithID[T any](h func(w http.ResponseWriter, r *http.Request, id string) (T, *Error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ApiErrorResponse{
+				Code:    "MISSING_ID",
+				Message: "Rule ID is required",
+			})
+			return
+		}
+
+		res, apiErr := h(w, r, id)
+		encoder := json.NewEncoder(w)
+
+		if apiErr != nil {
+			w.WriteHeader(apiErr.StatusCode)
+			encoder.Encode(ApiErrorResponse{
+				Code:    apiErr.Code,
+				Message: apiErr.Message,
+				Meta:    apiErr.Meta,
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		encoder.Encode(res)
+	}
+}
+
 func WrapWithBodyAndID[Req any, Res any](h func(w http.ResponseWriter, r *http.Request, id string, body *Req) (Res, *Error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -1,50 +1,280 @@
 package telegram
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Bot struct {
-	api            *tgbotapi.BotAPI
-	targetChatID   int64
-	targetUsername string
+	api *tgbotapi.BotAPI
 }
 
-func NewBot(token string, targetChatID int64, targetUsername string) (*Bot, error) {
+func NewBot(token string) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 
-	log.Printf("Authorized bot account: %s (ID: %d)", api.Self.UserName, api.Self.ID)
+	slog.Info("Authorized bot account", "bot", api.Self.UserName, "bot_id", api.Self.ID)
 
-	return &Bot{
-		api:            api,
-		targetChatID:   targetChatID,
-		targetUsername: targetUsername,
-	}, nil
+	return &Bot{api: api}, nil
 }
 
 func (b *Bot) GetBotID() int64 {
 	return b.api.Self.ID
 }
 
-func (b *Bot) ForwardMessage(text string) error {
+type SendOptions struct {
+	DisableNotification bool
+	Feedback            *FeedbackRef
+}
+
+type Target struct {
+	ChatID   int64
+	Username string
+}
+
+func (b *Bot) Ping(ctx context.Context) error {
+	if _, err := b.api.GetMe(); err != nil {
+		return fmt.Errorf("bot API unreachable: %w", err)
+	}
+	return nil
+}
+
+func (b *Bot) SendMessage(ctx context.Context, target Target, text string, opts SendOptions) error {
+	_, span := tracer.Start(ctx, "telegram.bot.send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("bot.username", b.api.Self.UserName),
+		attribute.Int64("target.chat_id", target.ChatID),
+		attribute.String("target.username", target.Username),
+	))
+	defer span.End()
+
 	var msg tgbotapi.MessageConfig
 
-	if b.targetChatID != 0 {
-		msg = tgbotapi.NewMessage(b.targetChatID, text)
+	if target.ChatID != 0 {
+		msg = tgbotapi.NewMessage(target.ChatID, text)
 	} else {
-		msg = tgbotapi.NewMessageToChannel("@"+b.targetUsername, text)
+		msg = tgbotapi.NewMessageToChannel("@"+target.Username, text)
+	}
+	msg.DisableNotification = opts.DisableNotification
+	if opts.Feedback != nil {
+		msg.ReplyMarkup = feedbackKeyboard(*opts.Feedback)
 	}
 
-	if _, err := b.api.Send(msg); err != nil {
+	start := time.Now()
+	_, err := b.api.Send(msg)
+	metrics.BotSendDuration.WithLabelValues(b.api.Self.UserName).Observe(time.Since(start).Seconds())
+	if err != nil {
+		code := errorCode(err)
+		metrics.BotSendErrors.WithLabelValues(b.api.Self.UserName, code).Inc()
+		span.SetAttributes(attribute.String("telegram.error_code", code))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
-	log.Printf("Message forwarded successfully")
+	slog.InfoContext(ctx, "Message forwarded", "bot", b.api.Self.UserName, "chat_id", target.ChatID, "username", target.Username)
+	return nil
+}
+
+func errorCode(err error) string {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code != 0 {
+		return strconv.Itoa(apiErr.Code)
+	}
+	return "network"
+}
+
+func IsPermanent(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == 400 || apiErr.Code == 403)
+}
+
+type botTarget struct {
+	Target
+	bot *Bot
+}
+
+type Bots struct {
+	mu            sync.RWMutex
+	bots          map[string]*Bot
+	targets       map[string]botTarget
+	defaultTarget string
+}
+
+func NewBots() *Bots {
+	return &Bots{
+		bots:    map[string]*Bot{},
+		targets: map[string]botTarget{},
+	}
+}
+
+func (b *Bots) Add(name string, bot *Bot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bots[name] = bot
+}
+
+func (b *Bots) AddTarget(name, botName string, target Target) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bot, ok := b.bots[botName]
+	if !ok {
+		return fmt.Errorf("unknown bot %q for target %q", botName, name)
+	}
+
+	b.targets[name] = botTarget{Target: target, bot: bot}
+	if b.defaultTarget == "" {
+		b.defaultTarget = name
+	}
 	return nil
 }
+
+func (b *Bots) Get(name string) (*Bot, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bot, ok := b.bots[name]
+	return bot, ok
+}
+
+func (b *Bots) IDs() []int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ids := make([]int64, 0, len(b.bots))
+	for _, bot := range b.bots {
+		ids = append(ids, bot.GetBotID())
+	}
+	return ids
+}
+
+func (b *Bots) SendMessage(ctx context.Context, target, text string, opts SendOptions) error {
+	b.mu.RLock()
+	if target == "" {
+		target = b.defaultTarget
+	}
+	t, ok := b.targets[target]
+	b.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown delivery target %q", target)
+	}
+	return t.bot.SendMessage(ctx, t.Target, text, opts)
+}
+
+type Command struct {
+	UserID int64
+	Name   string
+	Args   string
+}
+
+const listenRetryDelay = 3 * time.Second
+
+type CommandHandler func(ctx context.Context, cmd Command) string
+
+func (b *Bot) Listen(ctx context.Context, onCommand CommandHandler, onFeedback FeedbackHandler) {
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = 30
+	config.AllowedUpdates = []string{"message", "callback_query"}
+
+	slog.Info("Bot is listening for commands and feedback", "bot", b.api.Self.UserName)
+
+	for ctx.Err() == nil {
+		updates, err := b.getUpdates(ctx, config)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to get bot updates, retrying", "bot", b.api.Self.UserName, "error", err)
+			if err := sleep(ctx, listenRetryDelay); err != nil {
+				return
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if ctx.Err() != nil {
+				return
+			}
+			config.Offset = update.UpdateID + 1
+
+			switch {
+			case update.Message != nil && onCommand != nil:
+				b.handleCommand(ctx, update.Message, onCommand)
+			case update.CallbackQuery != nil && onFeedback != nil:
+				b.handleFeedback(ctx, update.CallbackQuery, onFeedback)
+			}
+		}
+	}
+}
+
+type updatesResult struct {
+	updates []tgbotapi.Update
+	err     error
+}
+
+func (b *Bot) getUpdates(ctx context.Context, config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	result := make(chan updatesResult, 1)
+	go func() {
+		updates, err := b.api.GetUpdates(config)
+		result <- updatesResult{updates: updates, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-result:
+		return r.updates, r.err
+	}
+}
+
+func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message, handler CommandHandler) {
+	if msg.From == nil || !msg.IsCommand() {
+		return
+	}
+
+	reply := handler(ctx, Command{
+		UserID: msg.From.ID,
+		Name:   msg.Command(),
+		Args:   strings.TrimSpace(msg.CommandArguments()),
+	})
+	if reply == "" {
+		return
+	}
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, reply)
+	response.ReplyToMessageID = msg.MessageID
+	if _, err := b.api.Send(response); err != nil {
+		slog.ErrorContext(ctx, "Failed to reply to command", "command", msg.Command(), "error", err)
+	}
+}
+
+func (b *Bot) handleFeedback(ctx context.Context, query *tgbotapi.CallbackQuery, handler FeedbackHandler) {
+	feedback, ok := parseFeedback(query.Data)
+	if !ok {
+		if _, err := b.api.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+			slog.ErrorContext(ctx, "Failed to answer unknown callback", "error", err)
+		}
+		return
+	}
+	feedback.UserID = query.From.ID
+
+	answer := tgbotapi.NewCallback(query.ID, handler(ctx, feedback))
+	answer.ShowAlert = feedback.Action == FeedbackOpen
+	if _, err := b.api.Request(answer); err != nil {
+		slog.ErrorContext(ctx, "Failed to answer feedback", "action", feedback.Action, "error", err)
+	}
+}
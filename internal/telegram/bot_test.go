@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func commandUpdate(id int, command string) string {
+	return fmt.Sprintf(`{"update_id":%d,"message":{"message_id":%d,"from":{"id":1},"chat":{"id":1,"type":"private"},"text":%q,"entities":[{"type":"bot_command","offset":0,"length":%d}]}}`,
+		id, id, command, len(command))
+}
+
+func testBot(t *testing.T, updates string) *Bot {
+	var polls atomic.Int32
+	hold := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			fmt.Fprint(w, `{"ok":true,"result":{"id":42,"is_bot":true,"username":"test_bot"}}`)
+		case strings.HasSuffix(r.URL.Path, "/getUpdates") && polls.Add(1) == 1:
+			fmt.Fprintf(w, `{"ok":true,"result":[%s]}`, updates)
+		default:
+			<-hold
+			fmt.Fprint(w, `{"ok":true,"result":[]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(hold) })
+
+	api, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	require.NoError(t, err)
+	return &Bot{api: api}
+}
+
+func TestBotListen(t *testing.T) {
+	t.Run("should return while a poll is in flight when ctx is cancelled", func(t *testing.T) {
+		bot := testBot(t, "")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			bot.Listen(ctx, nil, nil)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Listen did not return after ctx was cancelled")
+		}
+	})
+
+	t.Run("should not handle updates left in the batch once ctx is cancelled", func(t *testing.T) {
+		bot := testBot(t, commandUpdate(1, "/first")+","+commandUpdate(2, "/second"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var handled []string
+		bot.Listen(ctx, func(ctx context.Context, cmd Command) string {
+			handled = append(handled, cmd.Name)
+			cancel()
+			return ""
+		}, nil)
+
+		require.Equal(t, []string{"first"}, handled)
+	})
+}
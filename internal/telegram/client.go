@@ -4,35 +4,62 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"slices"
 	"strconv"
-
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/logging"
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/telegram/updates/hook"
 	"github.com/gotd/td/tg"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type MessageHandler func(ctx context.Context, message *tg.Message) error
+type MessageHandler func(ctx context.Context, message *tg.Message, match *matcher.Message) error
+
+var authMu sync.Mutex
+
+var tracer = otel.Tracer("github.com/gabrielmelo/tg-forward/internal/telegram")
 
 type Client struct {
+	account       string
 	client        *telegram.Client
 	phone         string
 	handler       MessageHandler
-	api           *tg.Client
-	botID         int64
+	api           atomic.Pointer[tg.Client]
+	botIDs        []int64
 	sessionString string
 	sessionStore  session.Storage
+	admins        *adminCache
+	state         *StateRepository
+	catchUpMaxAge time.Duration
+	authorized    atomic.Bool
+	listening     atomic.Bool
 }
 
-func NewClient(appID int, appHash, phone string, handler MessageHandler, botID int64, sessionString string) *Client {
+func NewClient(account string, appID int, appHash, phone string, handler MessageHandler, botIDs []int64, sessionString string) *Client {
 	return &Client{
+		account:       account,
 		phone:         phone,
 		handler:       handler,
-		botID:         botID,
+		botIDs:        botIDs,
 		sessionString: sessionString,
+		admins:        newAdminCache(),
 	}
 }
 
@@ -53,16 +80,30 @@ func (c *Client) Run(ctx context.Context, appID int, appHash string) error {
 			return fmt.Errorf("failed to save decoded session: %w", err)
 		}
 
-		log.Println("Using Telethon session string from environment variable")
+		slog.Info("Using Telethon session string from environment variable", "account", c.account)
 	} else {
-		log.Println("No session string provided - will require 2FA authentication")
+		slog.Info("No session string provided, 2FA authentication will be required", "account", c.account)
 	}
 
 	c.sessionStore = sessionStorage
 
+	gotdLog := logging.Zap(slog.Default().With("account", c.account))
+	updatesConfig := updates.Config{
+		Handler:          dispatcher,
+		OnChannelTooLong: onChannelTooLong,
+		Logger:           gotdLog.Named("updates"),
+	}
+	if c.state != nil {
+		updatesConfig.Storage = c.state
+		updatesConfig.AccessHasher = c.state
+	}
+	gaps := updates.New(updatesConfig)
+
 	client := telegram.NewClient(appID, appHash, telegram.Options{
-		UpdateHandler:  dispatcher,
+		UpdateHandler:  gaps,
 		SessionStorage: sessionStorage,
+		Middlewares:    []telegram.Middleware{hook.UpdateHook(gaps.Handle)},
+		Logger:         gotdLog,
 	})
 	c.client = client
 
@@ -72,21 +113,7 @@ func (c *Client) Run(ctx context.Context, appID int, appHash string) error {
 			return nil
 		}
 
-		if msg.Out {
-			return nil
-		}
-
-		if peerUser, ok := msg.FromID.(*tg.PeerUser); ok {
-			if peerUser.UserID == c.botID {
-				log.Printf("Ignoring channel message from bot (ID: %d)", c.botID)
-				return nil
-			}
-		}
-
-		if c.handler != nil {
-			return c.handler(ctx, msg)
-		}
-		return nil
+		return c.handleChannelMessage(ctx, e, msg)
 	})
 
 	return client.Run(ctx, func(ctx context.Context) error {
@@ -100,26 +127,112 @@ func (c *Client) Run(ctx context.Context, appID int, appHash string) error {
 			return fmt.Errorf("authentication failed: %w", err)
 		}
 
-		c.api = client.API()
+		api := client.API()
+		c.api.Store(api)
+		defer c.api.Store(nil)
+		c.authorized.Store(true)
+		defer c.authorized.Store(false)
 
-		log.Println("Successfully authenticated as user")
+		slog.InfoContext(ctx, "Authenticated as user", "account", c.account)
 
 		if wasNotAuthorized && !usingEnvSession {
-			self, err := c.api.UsersGetFullUser(ctx, &tg.InputUserSelf{})
+			self, err := api.UsersGetFullUser(ctx, &tg.InputUserSelf{})
 			if err == nil {
 				_ = self
 			}
 
 			if err := c.printSessionString(ctx); err != nil {
-				log.Printf("Warning: Failed to print session string: %v", err)
+				slog.WarnContext(ctx, "Failed to print session string", "account", c.account, "error", err)
 			}
 		}
 
-		<-ctx.Done()
-		return ctx.Err()
+		self, err := client.Self(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get current user: %w", err)
+		}
+
+		connected := metrics.UserClientConnected.WithLabelValues(c.account)
+		defer connected.Set(0)
+		defer c.listening.Store(false)
+
+		if err := c.skipStaleState(ctx, self.ID); err != nil {
+			return err
+		}
+
+		return gaps.Run(ctx, differenceCounter{api}, self.ID, updates.AuthOptions{
+			OnStart: func(ctx context.Context) {
+				go c.heartbeat(ctx, self.ID)
+				connected.Set(1)
+				c.listening.Store(true)
+				slog.InfoContext(ctx, "Listening for updates", "account", c.account)
+			},
+		})
 	})
 }
 
+func chatType(e tg.Entities, peer tg.PeerClass) string {
+	p, ok := peer.(*tg.PeerChannel)
+	if !ok {
+		return "unknown"
+	}
+
+	if channel, ok := e.Channels[p.ChannelID]; ok && channel.Megagroup {
+		return "supergroup"
+	}
+	return "channel"
+}
+
+func (c *Client) handleChannelMessage(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	chat := chatType(e, msg.PeerID)
+	metrics.MessagesReceived.WithLabelValues(chat).Inc()
+
+	correlationID := logging.NewCorrelationID()
+	ctx = logging.With(logging.WithCorrelationID(ctx, correlationID),
+		slog.String("account", c.account),
+		slog.Int64("chat_id", peerID(msg.PeerID)),
+		slog.Int("message_id", msg.ID),
+	)
+
+	ctx, span := tracer.Start(ctx, "telegram.message", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.String("telegram.account", c.account),
+		attribute.String("telegram.chat_type", chat),
+		attribute.Int64("telegram.chat_id", peerID(msg.PeerID)),
+		attribute.Int("telegram.message_id", msg.ID),
+		attribute.String(logging.CorrelationKey, correlationID),
+	))
+	defer span.End()
+
+	if msg.Out {
+		span.SetAttributes(attribute.String("telegram.ignored", "outgoing"))
+		return nil
+	}
+
+	if c.tooOld(msg) {
+		slog.DebugContext(ctx, "Ignoring missed message older than the catch-up limit")
+		span.SetAttributes(attribute.String("telegram.ignored", "too_old"))
+		return nil
+	}
+
+	if peerUser, ok := msg.FromID.(*tg.PeerUser); ok {
+		if slices.Contains(c.botIDs, peerUser.UserID) {
+			slog.DebugContext(ctx, "Ignoring channel message from bot", "bot_id", peerUser.UserID)
+			span.SetAttributes(attribute.String("telegram.ignored", "bot"))
+			return nil
+		}
+	}
+
+	if c.handler == nil {
+		return nil
+	}
+
+	if err := c.handler(ctx, msg, c.matchMessage(ctx, e, msg)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
 func (c *Client) printSessionString(ctx context.Context) error {
 	loader := session.Loader{Storage: c.sessionStore}
 	sessionData, err := loader.Load(ctx)
@@ -147,18 +260,35 @@ func (c *Client) printSessionString(ctx context.Context) error {
 	fmt.Println("🔑 Session authenticated successfully!")
 	fmt.Println(separator)
 	fmt.Println("\nAdd this to your environment to avoid 2FA on every restart:")
-	fmt.Printf("\nTG_USER_SESSION=%s\n", sessionString)
+	fmt.Printf("\n%s=%s\n", c.sessionEnvKey(), sessionString)
 	fmt.Printf("\nSession size: %d characters\n", len(sessionString))
 	fmt.Println("\n" + separator + "\n")
 
 	return nil
 }
 
+func (c *Client) Ready(ctx context.Context) error {
+	if !c.authorized.Load() {
+		return errors.New("not authenticated")
+	}
+	if !c.listening.Load() {
+		return errors.New("not listening for updates")
+	}
+	return nil
+}
+
+func (c *Client) Account() string {
+	return c.account
+}
+
 func (c *Client) authenticate(ctx context.Context) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
 	flow := auth.NewFlow(
 		auth.Constant(c.phone, "", auth.CodeAuthenticatorFunc(func(ctx context.Context, sentCode *tg.AuthSentCode) (string, error) {
 			var code string
-			fmt.Print("Enter code: ")
+			fmt.Printf("Enter code for account %q (%s): ", c.account, c.phone)
 			if _, err := fmt.Scanln(&code); err != nil {
 				return "", err
 			}
@@ -174,6 +304,13 @@ func (c *Client) authenticate(ctx context.Context) error {
 	return nil
 }
 
+func (c *Client) sessionEnvKey() string {
+	if c.account == "" || c.account == "default" {
+		return "TG_USER_SESSION"
+	}
+	return "TG_USER_" + strings.ToUpper(c.account) + "_SESSION"
+}
+
 func decodeTelethonSession(sessionStr string) (*session.Data, error) {
 	return session.TelethonSession(sessionStr)
 }
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type FeedbackAction string
+
+const (
+	FeedbackMute        FeedbackAction = "mute"
+	FeedbackMuteSource  FeedbackAction = "src"
+	FeedbackNotRelevant FeedbackAction = "nr"
+	FeedbackOpen        FeedbackAction = "open"
+)
+
+type FeedbackRef struct {
+	RuleID string
+	Source int64
+}
+
+type Feedback struct {
+	FeedbackRef
+	Action FeedbackAction
+	UserID int64
+}
+
+type FeedbackHandler func(ctx context.Context, feedback Feedback) string
+
+func feedbackKeyboard(ref FeedbackRef) tgbotapi.InlineKeyboardMarkup {
+	button := func(text string, action FeedbackAction) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, encodeFeedback(action, ref))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button("Mute rule 1h", FeedbackMute),
+			button("Mute this source", FeedbackMuteSource),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button("Not relevant", FeedbackNotRelevant),
+			button("Open rule", FeedbackOpen),
+		),
+	)
+}
+
+func encodeFeedback(action FeedbackAction, ref FeedbackRef) string {
+	return string(action) + ":" + ref.RuleID + ":" + strconv.FormatInt(ref.Source, 10)
+}
+
+func parseFeedback(data string) (Feedback, bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 3 || parts[1] == "" {
+		return Feedback{}, false
+	}
+
+	action := FeedbackAction(parts[0])
+	switch action {
+	case FeedbackMute, FeedbackMuteSource, FeedbackNotRelevant, FeedbackOpen:
+	default:
+		return Feedback{}, false
+	}
+
+	source, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Feedback{}, false
+	}
+
+	return Feedback{
+		FeedbackRef: FeedbackRef{RuleID: parts[1], Source: source},
+		Action:      action,
+	}, true
+}
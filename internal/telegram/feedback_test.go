@@ -0,0 +1,42 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedbackData(t *testing.T) {
+	t.Run("should round-trip callback data", func(t *testing.T) {
+		ref := FeedbackRef{RuleID: "0b5f3c3e-5a8e-4c1e-9d4a-3f0f6f1b2c7d", Source: 1234567890123}
+
+		for _, action := range []FeedbackAction{FeedbackMute, FeedbackMuteSource, FeedbackNotRelevant, FeedbackOpen} {
+			data := encodeFeedback(action, ref)
+			require.LessOrEqual(t, len(data), 64)
+
+			feedback, ok := parseFeedback(data)
+			require.True(t, ok)
+			require.Equal(t, action, feedback.Action)
+			require.Equal(t, ref, feedback.FeedbackRef)
+		}
+	})
+
+	t.Run("should reject unknown data", func(t *testing.T) {
+		for _, data := range []string{"", "mute", "mute::1", "delete:rule:1", "mute:rule:abc", "mute:rule:1:extra"} {
+			_, ok := parseFeedback(data)
+			require.False(t, ok, data)
+		}
+	})
+
+	t.Run("should build a keyboard with every action", func(t *testing.T) {
+		keyboard := feedbackKeyboard(FeedbackRef{RuleID: "rule", Source: 7})
+
+		var data []string
+		for _, row := range keyboard.InlineKeyboard {
+			for _, button := range row {
+				data = append(data, *button.CallbackData)
+			}
+		}
+		require.Equal(t, []string{"mute:rule:7", "src:rule:7", "nr:rule:7", "open:rule:7"}, data)
+	})
+}
@@ -0,0 +1,189 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+const (
+	historyPageSize  = 100
+	historyPageDelay = 500 * time.Millisecond
+)
+
+var (
+	ErrChatUnavailable = errors.New("chat is not available to this account")
+	ErrNotConnected    = errors.New("telegram client is not connected")
+)
+
+type HistoryMessage struct {
+	Chat    string
+	ID      int
+	Date    time.Time
+	Message *matcher.Message
+}
+
+func (c *Client) History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(HistoryMessage) error) error {
+	api := c.api.Load()
+	if api == nil {
+		return ErrNotConnected
+	}
+
+	peer, err := resolvePeer(ctx, api, chat)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrChatUnavailable, err)
+	}
+
+	offsetID := 0
+	offsetDate := int(until.Unix())
+	seen := 0
+
+	for limit <= 0 || seen < limit {
+		result, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:       peer,
+			OffsetID:   offsetID,
+			OffsetDate: offsetDate,
+			Limit:      historyPageSize,
+		})
+		if err != nil {
+			if wait, ok := tgerr.AsFloodWait(err); ok {
+				slog.WarnContext(ctx, "History is rate limited", "chat", chat, "wait", wait)
+				if err := sleep(ctx, wait+time.Second); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("failed to get history of %s: %w", chat, err)
+		}
+
+		page, ok := result.AsModified()
+		if !ok {
+			return nil
+		}
+
+		messages := page.GetMessages()
+		if len(messages) == 0 {
+			return nil
+		}
+
+		e := entitiesOf(page.GetUsers(), page.GetChats())
+		for _, m := range messages {
+			msg, ok := m.(*tg.Message)
+			if !ok {
+				offsetID = m.GetID()
+				continue
+			}
+
+			offsetID = msg.ID
+			date := time.Unix(int64(msg.Date), 0)
+			if date.Before(since) {
+				return nil
+			}
+			match := c.matchMessage(ctx, e, msg)
+			if match.Text == "" && match.Media == "" {
+				continue
+			}
+
+			if err := fn(HistoryMessage{
+				Chat:    chat,
+				ID:      msg.ID,
+				Date:    date,
+				Message: match,
+			}); err != nil {
+				return err
+			}
+
+			seen++
+			if limit > 0 && seen >= limit {
+				return nil
+			}
+		}
+
+		offsetDate = 0
+		if len(messages) < historyPageSize {
+			return nil
+		}
+
+		if err := sleep(ctx, historyPageDelay); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resolvePeer(ctx context.Context, api *tg.Client, chat string) (tg.InputPeerClass, error) {
+	username := strings.TrimPrefix(chat, "https://")
+	username = strings.TrimPrefix(username, "t.me/")
+	username = strings.TrimPrefix(username, "@")
+	if username == "" {
+		return nil, fmt.Errorf("chat is required")
+	}
+
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: username})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", chat, err)
+	}
+
+	switch p := resolved.Peer.(type) {
+	case *tg.PeerChannel:
+		for _, ch := range resolved.Chats {
+			if channel, ok := ch.(*tg.Channel); ok && channel.ID == p.ChannelID {
+				return channel.AsInputPeer(), nil
+			}
+		}
+	case *tg.PeerUser:
+		for _, u := range resolved.Users {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				return user.AsInputPeer(), nil
+			}
+		}
+	case *tg.PeerChat:
+		return &tg.InputPeerChat{ChatID: p.ChatID}, nil
+	}
+
+	return nil, fmt.Errorf("failed to resolve %s: peer not found", chat)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func entitiesOf(users []tg.UserClass, chats []tg.ChatClass) tg.Entities {
+	e := tg.Entities{
+		Users:    map[int64]*tg.User{},
+		Chats:    map[int64]*tg.Chat{},
+		Channels: map[int64]*tg.Channel{},
+	}
+
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			e.Users[user.ID] = user
+		}
+	}
+	for _, ch := range chats {
+		switch chat := ch.(type) {
+		case *tg.Chat:
+			e.Chats[chat.ID] = chat
+		case *tg.Channel:
+			e.Channels[chat.ID] = chat
+		}
+	}
+
+	return e
+}
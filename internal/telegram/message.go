@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"context"
+	"unicode/utf16"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gotd/td/tg"
+)
+
+func (c *Client) matchMessage(ctx context.Context, e tg.Entities, msg *tg.Message) *matcher.Message {
+	ctx, span := tracer.Start(ctx, "telegram.extract")
+	defer span.End()
+
+	return &matcher.Message{
+		Account: c.account,
+		Chat:    peerID(msg.PeerID),
+		Text:    msg.Message,
+		Sender:  c.senderFor(ctx, e, msg),
+		Media:   mediaType(msg.Media),
+		URLs:    messageURLs(msg),
+	}
+}
+
+func peerID(peer tg.PeerClass) int64 {
+	switch p := peer.(type) {
+	case *tg.PeerChannel:
+		return p.ChannelID
+	case *tg.PeerChat:
+		return p.ChatID
+	case *tg.PeerUser:
+		return p.UserID
+	default:
+		return 0
+	}
+}
+
+func mediaType(media tg.MessageMediaClass) string {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty:
+		return ""
+	case *tg.MessageMediaPhoto:
+		return "photo"
+	case *tg.MessageMediaDocument:
+		return documentType(m)
+	case *tg.MessageMediaPoll:
+		return "poll"
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return "location"
+	case *tg.MessageMediaContact:
+		return "contact"
+	case *tg.MessageMediaWebPage:
+		return "webpage"
+	default:
+		return "other"
+	}
+}
+
+func documentType(media *tg.MessageMediaDocument) string {
+	doc, ok := media.Document.(*tg.Document)
+	if !ok {
+		return "document"
+	}
+
+	kind := "document"
+	for _, attr := range doc.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeSticker:
+			return "sticker"
+		case *tg.DocumentAttributeAnimated:
+			return "animation"
+		case *tg.DocumentAttributeVideo:
+			if a.RoundMessage {
+				kind = "video_note"
+			} else {
+				kind = "video"
+			}
+		case *tg.DocumentAttributeAudio:
+			if a.Voice {
+				kind = "voice"
+			} else {
+				kind = "audio"
+			}
+		}
+	}
+	return kind
+}
+
+func messageURLs(msg *tg.Message) []string {
+	var urls []string
+	text := utf16.Encode([]rune(msg.Message))
+
+	for _, entity := range msg.Entities {
+		switch e := entity.(type) {
+		case *tg.MessageEntityURL:
+			if e.Offset >= 0 && e.Offset+e.Length <= len(text) {
+				urls = append(urls, string(utf16.Decode(text[e.Offset:e.Offset+e.Length])))
+			}
+		case *tg.MessageEntityTextURL:
+			urls = append(urls, e.URL)
+		}
+	}
+
+	if media, ok := msg.Media.(*tg.MessageMediaWebPage); ok {
+		if page, ok := media.Webpage.(*tg.WebPage); ok && page.URL != "" {
+			urls = append(urls, page.URL)
+		}
+	}
+
+	return urls
+}
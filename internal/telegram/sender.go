@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/matcher"
+	"github.com/gotd/td/tg"
+)
+
+const adminCacheTTL = 10 * time.Minute
+
+type adminKey struct {
+	channelID int64
+	userID    int64
+}
+
+type adminEntry struct {
+	admin   bool
+	expires time.Time
+}
+
+type adminCache struct {
+	mu      sync.Mutex
+	entries map[adminKey]adminEntry
+}
+
+func newAdminCache() *adminCache {
+	return &adminCache{entries: map[adminKey]adminEntry{}}
+}
+
+func (c *adminCache) get(key adminKey, now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		return false, false
+	}
+	return entry.admin, true
+}
+
+func (c *adminCache) set(key adminKey, admin bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = adminEntry{admin: admin, expires: now.Add(adminCacheTTL)}
+}
+
+func (c *Client) senderFor(ctx context.Context, e tg.Entities, msg *tg.Message) matcher.Sender {
+	var sender matcher.Sender
+
+	channel := channelFor(e, msg.PeerID)
+
+	switch from := msg.FromID.(type) {
+	case *tg.PeerUser:
+		sender.ID = from.UserID
+		if user, ok := e.Users[from.UserID]; ok {
+			sender.Username = user.Username
+			sender.Bot = user.Bot
+			sender.Premium = user.Premium
+			sender.Verified = user.Verified
+		}
+		sender.IsAdmin = func() bool {
+			return c.isAdmin(ctx, channel, e.Users[from.UserID], from.UserID)
+		}
+	case *tg.PeerChannel:
+		sender.ID = from.ChannelID
+		if ch, ok := e.Channels[from.ChannelID]; ok {
+			sender.Username = ch.Username
+			sender.Verified = ch.Verified
+		}
+		sender.IsAdmin = func() bool { return true }
+	default:
+		if channel != nil {
+			sender.ID = channel.ID
+			sender.Username = channel.Username
+			sender.Verified = channel.Verified
+		}
+		sender.IsAdmin = func() bool { return channel != nil && channel.Broadcast }
+	}
+
+	if fwd, ok := msg.GetFwdFrom(); ok {
+		switch from := fwd.FromID.(type) {
+		case *tg.PeerUser:
+			sender.ForwardedFromID = from.UserID
+			if user, ok := e.Users[from.UserID]; ok {
+				sender.ForwardedFromUsername = user.Username
+			}
+		case *tg.PeerChannel:
+			sender.ForwardedFromID = from.ChannelID
+			if ch, ok := e.Channels[from.ChannelID]; ok {
+				sender.ForwardedFromUsername = ch.Username
+			}
+		}
+	}
+
+	return sender
+}
+
+func (c *Client) isAdmin(ctx context.Context, channel *tg.Channel, user *tg.User, userID int64) bool {
+	api := c.api.Load()
+	if channel == nil || api == nil {
+		return false
+	}
+
+	key := adminKey{channelID: channel.ID, userID: userID}
+	if admin, ok := c.admins.get(key, time.Now()); ok {
+		return admin
+	}
+
+	var participant tg.InputPeerClass = &tg.InputPeerUser{UserID: userID}
+	if user != nil {
+		participant = user.AsInputPeer()
+	}
+
+	result, err := api.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+		Channel:     channel.AsInput(),
+		Participant: participant,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check admin status", "user_id", userID, "error", err)
+		return false
+	}
+
+	admin := false
+	switch result.Participant.(type) {
+	case *tg.ChannelParticipantAdmin, *tg.ChannelParticipantCreator:
+		admin = true
+	}
+
+	c.admins.set(key, admin, time.Now())
+	return admin
+}
+
+func channelFor(e tg.Entities, peer tg.PeerClass) *tg.Channel {
+	if p, ok := peer.(*tg.PeerChannel); ok {
+		return e.Channels[p.ChannelID]
+	}
+	return nil
+}
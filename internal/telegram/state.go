@@ -0,0 +1,210 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/telegram/updates"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type channelKey struct {
+	UserID    int64 `bson:"user_id"`
+	ChannelID int64 `bson:"channel_id"`
+}
+
+type channelState struct {
+	Key        channelKey `bson:"_id"`
+	Pts        int        `bson:"pts"`
+	AccessHash int64      `bson:"access_hash"`
+}
+
+type userState struct {
+	UserID int64     `bson:"_id"`
+	Pts    int       `bson:"pts"`
+	Qts    int       `bson:"qts"`
+	Date   int       `bson:"date"`
+	Seq    int       `bson:"seq"`
+	SeenAt time.Time `bson:"seen_at,omitempty"`
+}
+
+type StateRepository struct {
+	states   *mongo.Collection
+	channels *mongo.Collection
+}
+
+var (
+	_ updates.StateStorage        = (*StateRepository)(nil)
+	_ updates.ChannelAccessHasher = (*StateRepository)(nil)
+)
+
+func NewStateRepository(client *mongo.Client, database string) *StateRepository {
+	db := client.Database(database)
+	return &StateRepository{
+		states:   db.Collection("update_states"),
+		channels: db.Collection("update_channels"),
+	}
+}
+
+func (r *StateRepository) GetState(ctx context.Context, userID int64) (updates.State, bool, error) {
+	var state userState
+	err := r.states.FindOne(ctx, bson.M{"_id": userID}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return updates.State{}, false, nil
+	}
+	if err != nil {
+		return updates.State{}, false, fmt.Errorf("failed to load update state: %w", err)
+	}
+
+	return updates.State{Pts: state.Pts, Qts: state.Qts, Date: state.Date, Seq: state.Seq}, true, nil
+}
+
+func (r *StateRepository) SetState(ctx context.Context, userID int64, state updates.State) error {
+	return r.setFields(ctx, userID, bson.M{
+		"pts":  state.Pts,
+		"qts":  state.Qts,
+		"date": state.Date,
+		"seq":  state.Seq,
+	})
+}
+
+func (r *StateRepository) SetPts(ctx context.Context, userID int64, pts int) error {
+	return r.setFields(ctx, userID, bson.M{"pts": pts})
+}
+
+func (r *StateRepository) SetQts(ctx context.Context, userID int64, qts int) error {
+	return r.setFields(ctx, userID, bson.M{"qts": qts})
+}
+
+func (r *StateRepository) SetDate(ctx context.Context, userID int64, date int) error {
+	return r.setFields(ctx, userID, bson.M{"date": date})
+}
+
+func (r *StateRepository) SetSeq(ctx context.Context, userID int64, seq int) error {
+	return r.setFields(ctx, userID, bson.M{"seq": seq})
+}
+
+func (r *StateRepository) SetDateSeq(ctx context.Context, userID int64, date, seq int) error {
+	return r.setFields(ctx, userID, bson.M{"date": date, "seq": seq})
+}
+
+func (r *StateRepository) GetChannelPts(ctx context.Context, userID, channelID int64) (int, bool, error) {
+	state, found, err := r.channel(ctx, userID, channelID)
+	if err != nil || !found || state.Pts == 0 {
+		return 0, false, err
+	}
+	return state.Pts, true, nil
+}
+
+func (r *StateRepository) SetChannelPts(ctx context.Context, userID, channelID int64, pts int) error {
+	return r.setChannelFields(ctx, userID, channelID, bson.M{"pts": pts})
+}
+
+func (r *StateRepository) ForEachChannels(ctx context.Context, userID int64, f func(ctx context.Context, channelID int64, pts int) error) error {
+	cursor, err := r.channels.Find(ctx, bson.M{"_id.user_id": userID, "pts": bson.M{"$gt": 0}})
+	if err != nil {
+		return fmt.Errorf("failed to load channel states: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var state channelState
+		if err := cursor.Decode(&state); err != nil {
+			return fmt.Errorf("failed to decode channel state: %w", err)
+		}
+		if err := f(ctx, state.Key.ChannelID, state.Pts); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *StateRepository) SetChannelAccessHash(ctx context.Context, userID, channelID, accessHash int64) error {
+	return r.setChannelFields(ctx, userID, channelID, bson.M{"access_hash": accessHash})
+}
+
+func (r *StateRepository) GetChannelAccessHash(ctx context.Context, userID, channelID int64) (int64, bool, error) {
+	state, found, err := r.channel(ctx, userID, channelID)
+	if err != nil || !found || state.AccessHash == 0 {
+		return 0, false, err
+	}
+	return state.AccessHash, true, nil
+}
+
+func (r *StateRepository) touch(ctx context.Context, userID int64, at time.Time) error {
+	if _, err := r.states.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"seen_at": at}}); err != nil {
+		return fmt.Errorf("failed to save update state: %w", err)
+	}
+	return nil
+}
+
+func (r *StateRepository) forgetBefore(ctx context.Context, userID int64, cutoff time.Time) (bool, error) {
+	var state userState
+	err := r.states.FindOne(ctx, bson.M{"_id": userID}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load update state: %w", err)
+	}
+
+	if !state.lastSeen().Before(cutoff) {
+		return false, nil
+	}
+
+	if _, err := r.channels.DeleteMany(ctx, bson.M{"_id.user_id": userID}); err != nil {
+		return false, fmt.Errorf("failed to reset channel states: %w", err)
+	}
+	if _, err := r.states.DeleteOne(ctx, bson.M{"_id": userID}); err != nil {
+		return false, fmt.Errorf("failed to reset update state: %w", err)
+	}
+	return true, nil
+}
+
+func (s userState) lastSeen() time.Time {
+	if s.SeenAt.IsZero() {
+		return time.Unix(int64(s.Date), 0)
+	}
+	return s.SeenAt
+}
+
+func (r *StateRepository) setFields(ctx context.Context, userID int64, fields bson.M) error {
+	_, err := r.states.UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": fields},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save update state: %w", err)
+	}
+	return nil
+}
+
+func (r *StateRepository) channel(ctx context.Context, userID, channelID int64) (channelState, bool, error) {
+	var state channelState
+	err := r.channels.FindOne(ctx, bson.M{"_id": channelKey{UserID: userID, ChannelID: channelID}}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return channelState{}, false, nil
+	}
+	if err != nil {
+		return channelState{}, false, fmt.Errorf("failed to load channel state: %w", err)
+	}
+	return state, true, nil
+}
+
+func (r *StateRepository) setChannelFields(ctx context.Context, userID, channelID int64, fields bson.M) error {
+	_, err := r.channels.UpdateOne(
+		ctx,
+		bson.M{"_id": channelKey{UserID: userID, ChannelID: channelID}},
+		bson.M{"$set": fields},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save channel state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	minRestartDelay = 5 * time.Second
+	maxRestartDelay = 5 * time.Minute
+)
+
+type Supervisor struct {
+	clients []*Client
+	appIDs  map[string]int
+	hashes  map[string]string
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		appIDs: map[string]int{},
+		hashes: map[string]string{},
+	}
+}
+
+func (s *Supervisor) Add(client *Client, appID int, appHash string) {
+	s.clients = append(s.clients, client)
+	s.appIDs[client.account] = appID
+	s.hashes[client.account] = appHash
+}
+
+func (s *Supervisor) Clients() []*Client {
+	return s.clients
+}
+
+func (s *Supervisor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, client := range s.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.supervise(ctx, client)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (s *Supervisor) supervise(ctx context.Context, client *Client) {
+	delay := minRestartDelay
+
+	for {
+		slog.InfoContext(ctx, "Starting user client", "account", client.account)
+		started := time.Now()
+
+		err := client.Run(ctx, s.appIDs[client.account], s.hashes[client.account])
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+		slog.ErrorContext(ctx, "User client stopped, restarting", "account", client.account, "error", err, "delay", delay)
+
+		if err := sleep(ctx, delay); err != nil {
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+func (s *Supervisor) History(ctx context.Context, chat string, since, until time.Time, limit int, fn func(HistoryMessage) error) error {
+	var errs []error
+	connected := false
+	for _, client := range s.clients {
+		err := client.History(ctx, chat, since, until, limit, fn)
+		if errors.Is(err, ErrNotConnected) {
+			errs = append(errs, fmt.Errorf("account %q: %v", client.account, err))
+			continue
+		}
+		connected = true
+		if !errors.Is(err, ErrChatUnavailable) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("account %q: %w", client.account, err))
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("no telegram accounts configured")
+	}
+	if !connected {
+		return ErrNotConnected
+	}
+	return errors.Join(errs...)
+}
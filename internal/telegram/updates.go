@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+)
+
+func (c *Client) EnableCatchUp(store *StateRepository, maxAge time.Duration) {
+	c.state = store
+	c.catchUpMaxAge = maxAge
+}
+
+const stateHeartbeat = time.Minute
+
+func (c *Client) skipStaleState(ctx context.Context, userID int64) error {
+	if c.state == nil || c.catchUpMaxAge <= 0 {
+		return nil
+	}
+
+	skipped, err := c.state.forgetBefore(ctx, userID, time.Now().Add(-c.catchUpMaxAge))
+	if err != nil {
+		return err
+	}
+	if skipped {
+		slog.WarnContext(ctx, "Offline for longer than the catch-up limit, skipping missed updates", "account", c.account, "max_age", c.catchUpMaxAge)
+	}
+	return nil
+}
+
+func (c *Client) heartbeat(ctx context.Context, userID int64) {
+	if c.state == nil {
+		return
+	}
+
+	ticker := time.NewTicker(stateHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		if err := c.state.touch(ctx, userID, time.Now()); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Failed to save update state heartbeat", "account", c.account, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) tooOld(msg *tg.Message) bool {
+	if c.catchUpMaxAge <= 0 {
+		return false
+	}
+	return time.Since(time.Unix(int64(msg.Date), 0)) > c.catchUpMaxAge
+}
+
+func onChannelTooLong(channelID int64) {
+	metrics.UpdateChannelTooLong.Inc()
+	slog.Warn("Update gap is too long to recover, missed messages are lost", "chat_id", channelID)
+}
+
+type differenceCounter struct {
+	updates.API
+}
+
+func (a differenceCounter) UpdatesGetDifference(ctx context.Context, request *tg.UpdatesGetDifferenceRequest) (tg.UpdatesDifferenceClass, error) {
+	metrics.UpdateDifferences.WithLabelValues("common").Inc()
+
+	diff, err := a.API.UpdatesGetDifference(ctx, request)
+	switch d := diff.(type) {
+	case *tg.UpdatesDifference:
+		metrics.UpdatesRecovered.WithLabelValues("common").Add(float64(len(d.NewMessages) + len(d.OtherUpdates)))
+	case *tg.UpdatesDifferenceSlice:
+		metrics.UpdatesRecovered.WithLabelValues("common").Add(float64(len(d.NewMessages) + len(d.OtherUpdates)))
+	}
+	return diff, err
+}
+
+func (a differenceCounter) UpdatesGetChannelDifference(ctx context.Context, request *tg.UpdatesGetChannelDifferenceRequest) (tg.UpdatesChannelDifferenceClass, error) {
+	metrics.UpdateDifferences.WithLabelValues("channel").Inc()
+
+	diff, err := a.API.UpdatesGetChannelDifference(ctx, request)
+	if d, ok := diff.(*tg.UpdatesChannelDifference); ok {
+		metrics.UpdatesRecovered.WithLabelValues("channel").Add(float64(len(d.NewMessages) + len(d.OtherUpdates)))
+	}
+	return diff, err
+}
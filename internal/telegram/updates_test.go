@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gabrielmelo/tg-forward/internal/metrics"
+	"github.com/gotd/td/tg"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUpdatesAPI struct {
+	difference        tg.UpdatesDifferenceClass
+	channelDifference tg.UpdatesChannelDifferenceClass
+}
+
+func (f fakeUpdatesAPI) UpdatesGetState(ctx context.Context) (*tg.UpdatesState, error) {
+	return &tg.UpdatesState{}, nil
+}
+
+func (f fakeUpdatesAPI) UpdatesGetDifference(ctx context.Context, request *tg.UpdatesGetDifferenceRequest) (tg.UpdatesDifferenceClass, error) {
+	return f.difference, nil
+}
+
+func (f fakeUpdatesAPI) UpdatesGetChannelDifference(ctx context.Context, request *tg.UpdatesGetChannelDifferenceRequest) (tg.UpdatesChannelDifferenceClass, error) {
+	return f.channelDifference, nil
+}
+
+func TestDifferenceCounter(t *testing.T) {
+	api := differenceCounter{fakeUpdatesAPI{
+		difference: &tg.UpdatesDifference{
+			NewMessages:  []tg.MessageClass{&tg.Message{ID: 1}, &tg.Message{ID: 2}},
+			OtherUpdates: []tg.UpdateClass{&tg.UpdateNewChannelMessage{Message: &tg.Message{ID: 3}}},
+		},
+		channelDifference: &tg.UpdatesChannelDifference{
+			NewMessages: []tg.MessageClass{&tg.Message{ID: 4}},
+		},
+	}}
+
+	differences := func(scope string) float64 {
+		return testutil.ToFloat64(metrics.UpdateDifferences.WithLabelValues(scope))
+	}
+	recovered := func(scope string) float64 {
+		return testutil.ToFloat64(metrics.UpdatesRecovered.WithLabelValues(scope))
+	}
+	common, channel := differences("common"), differences("channel")
+	recoveredCommon, recoveredChannel := recovered("common"), recovered("channel")
+
+	_, err := api.UpdatesGetDifference(context.Background(), &tg.UpdatesGetDifferenceRequest{})
+	require.NoError(t, err)
+	_, err = api.UpdatesGetChannelDifference(context.Background(), &tg.UpdatesGetChannelDifferenceRequest{})
+	require.NoError(t, err)
+
+	require.Equal(t, common+1, differences("common"))
+	require.Equal(t, channel+1, differences("channel"))
+	require.Equal(t, recoveredCommon+3, recovered("common"))
+	require.Equal(t, recoveredChannel+1, recovered("channel"))
+}
+
+func TestUserStateLastSeen(t *testing.T) {
+	seen := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, seen, userState{Date: int(seen.Add(-time.Hour).Unix()), SeenAt: seen}.lastSeen())
+	require.True(t, userState{Date: int(seen.Unix())}.lastSeen().Equal(seen))
+}
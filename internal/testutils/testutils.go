@@ -14,6 +14,7 @@ import (
 	"github.com/gabrielmelo/tg-forward/internal/rules"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/mongodb"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -69,13 +70,29 @@ type MongoDBConfig struct {
 }
 
 func SetupTestDB(t *testing.T) (*mongo.Client, string, func()) {
+	return setupDB(t, "")
+}
+
+func SetupReplicaSetDB(t *testing.T) (*mongo.Client, string, func()) {
+	return setupDB(t, "rs0")
+}
+
+func setupDB(t *testing.T, replicaSet string) (*mongo.Client, string, func()) {
 	ctx := context.Background()
 
-	mongodbContainer, err := mongodb.Run(ctx, "mongo:6")
+	var opts []testcontainers.ContainerCustomizer
+	if replicaSet != "" {
+		opts = append(opts, mongodb.WithReplicaSet(replicaSet))
+	}
+
+	mongodbContainer, err := mongodb.Run(ctx, "mongo:6", opts...)
 	require.NoError(t, err)
 
 	uri, err := mongodbContainer.ConnectionString(ctx)
 	require.NoError(t, err)
+	if replicaSet != "" {
+		uri += "&directConnection=true"
+	}
 
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	require.NoError(t, err)
@@ -115,7 +132,7 @@ func NewFixture(t *testing.T, client *mongo.Client, database string, apiToken st
 	require.NoError(t, err)
 
 	for _, pattern := range initialPatterns {
-		_, err := rulesRepo.AddRule("test-rule", pattern, nil)
+		_, err := rulesRepo.AddRule(rules.Rule{Name: "test-rule", Pattern: pattern})
 		require.NoError(t, err)
 	}
 
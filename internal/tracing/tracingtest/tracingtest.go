@@ -0,0 +1,28 @@
+package tracingtest
+
+import (
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	once     sync.Once
+	exporter = tracetest.NewInMemoryExporter()
+)
+
+func Record(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	once.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	})
+
+	exporter.Reset()
+	t.Cleanup(exporter.Reset)
+
+	return exporter
+}